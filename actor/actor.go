@@ -13,10 +13,15 @@ package actor // import "tideland.dev/go/together/actor"
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"runtime/debug"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"tideland.dev/go/together/fuse"
+	"tideland.dev/go/together/internal/goroutine"
 )
 
 //--------------------
@@ -28,10 +33,113 @@ const (
 	DefaultTimeout = 5 * time.Second
 
 	// DefaultQueueCap is the minimum and default capacity
-	// of the async actions queue.
+	// of each priority lane of the async actions queue.
 	DefaultQueueCap = 256
 )
 
+// QueuePolicy defines how an Actor handles new asynchronous
+// actions when its queue is full.
+type QueuePolicy int
+
+// Different policies for full queues.
+const (
+	// QueueBlock lets the caller wait until the action is queued
+	// or the timeout or context is done.
+	QueueBlock QueuePolicy = iota
+
+	// QueueReject immediately returns ErrQueueFull.
+	QueueReject
+
+	// QueueDropNewest silently drops the new action.
+	QueueDropNewest
+
+	// QueueDropOldest drops the oldest queued action to make
+	// room for the new one.
+	QueueDropOldest
+)
+
+// Priority defines the lane of an asynchronous action. Higher
+// lanes are processed first.
+type Priority int
+
+// Different priorities of asynchronous actions.
+const (
+	// PriorityNormal is used by DoAsync() and all synchronous
+	// actions.
+	PriorityNormal Priority = iota
+
+	// PriorityHigh is for control actions like shutdowns or
+	// configuration reloads.
+	PriorityHigh
+
+	// PriorityLow is for bulk actions.
+	PriorityLow
+)
+
+// Indexes of the lanes ordered by their priority.
+const (
+	highLane = iota
+	normalLane
+	lowLane
+
+	// priorities is the number of lanes.
+	priorities
+)
+
+// lane returns the index of the lane of the priority.
+func (p Priority) lane() int {
+	switch p {
+	case PriorityHigh:
+		return highLane
+	case PriorityLow:
+		return lowLane
+	default:
+		return normalLane
+	}
+}
+
+// starvationLimit is the number of actions taken from a lane
+// in a row before a waiting action of a lower lane is preferred.
+const starvationLimit = 16
+
+// reentrantDelay is the time a synchronous action waits for a
+// busy backend before it is checked for being sent from inside
+// the running action.
+const reentrantDelay = time.Millisecond
+
+// Reentrancy defines how an Actor handles synchronous actions
+// sent from inside one of its own actions.
+type Reentrancy int
+
+// Different handlings of reentrant synchronous actions.
+const (
+	// ReentrantError returns ErrReentrant.
+	ReentrantError Reentrancy = iota
+
+	// ReentrantInline executes the action directly.
+	ReentrantInline
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrQueueFull is returned when an action cannot be queued
+// because the queue is full.
+var ErrQueueFull = errors.New("actor queue is full")
+
+// ErrReentrant is returned when a synchronous action is sent from
+// inside an action of the same Actor, which would deadlock.
+var ErrReentrant = errors.New("actor reentrant synchronous call")
+
+// ErrDraining is returned when an action is sent to an Actor
+// that is stopping gracefully.
+var ErrDraining = errors.New("actor is draining")
+
+// ErrDropped is returned by a Future when its action has been
+// dropped by the queue policy or the termination of the Actor.
+var ErrDropped = errors.New("actor action dropped")
+
 //--------------------
 // FUNCTION TYPES
 //--------------------
@@ -39,16 +147,51 @@ const (
 // Action defines the signature of an actor action.
 type Action func()
 
+// ContextAction defines the signature of an actor action
+// receiving a context. It is cancelled when the caller gives
+// up or the Actor stops.
+type ContextAction func(ctx context.Context)
+
 // Repairer allows the Actor to react on a panic during its
 // work. If it returns nil the backend shall continue
 // work. Otherwise the error is stored and the backend
 // terminated.
 type Repairer func(reason interface{}) error
 
+// ContextRepairer allows the Actor to react on a panic with the
+// knowledge of the repair context. Its decision tells if and when
+// the backend continues work.
+type ContextRepairer func(rctx fuse.RepairContext) fuse.Decision
+
+// LinkHandler is called on the backend of an Actor when a
+// linked Actor terminated. The error is the final one of the
+// linked Actor.
+type LinkHandler func(err error)
+
 // Finalizer is called with the Actors internal status when
 // the backend loop terminates.
 type Finalizer func(err error) error
 
+//--------------------
+// ENVELOPE
+//--------------------
+
+// envelope wraps an action in a queue. The optional drop function
+// is called when the action is removed without being executed.
+type envelope struct {
+	sync     bool
+	enqueued time.Time
+	action   Action
+	drop     func()
+}
+
+// discard signals that the action of the envelope won't be executed.
+func (env *envelope) discard() {
+	if env.drop != nil {
+		env.drop()
+	}
+}
+
 //--------------------
 // ACTOR
 //--------------------
@@ -58,11 +201,40 @@ type Actor struct {
 	mu           sync.Mutex
 	ctx          context.Context
 	cancel       func()
-	asyncActions chan Action
-	syncActions  chan Action
-	repairer     Repairer
-	finalizer    Finalizer
+	asyncActions [priorities]chan *envelope
+	syncActions  chan *envelope
+	wake         chan struct{}
+	streaks      [priorities]int
+	queueCap     int
+	queuePolicy  QueuePolicy
+	interceptors []Interceptor
+	reentrancy   Reentrancy
+	backendID    uint64
+	busy         int32
+	runs         uint64
+	repairer     ContextRepairer
+	repairLog    *fuse.RepairLog
+	panicStack   []byte
+	finalizers   []Finalizer
 	works        atomic.Value
+	draining     bool
+	drainc       chan struct{}
+	done         chan struct{}
+	signal       *fuse.Signal
+	monitors     []chan error
+	exits        map[uint64]func()
+	exitID       uint64
+	links        map[*Actor]struct{}
+	futures      map[*Future]struct{}
+	linkHandler  LinkHandler
+	stats        *stats
+	idleTimeout  time.Duration
+	onPassivate  func()
+	onActivate   func()
+	passivated   bool
+	idle         chan struct{}
+	parent       context.Context
+	pending      int
 	err          error
 }
 
@@ -70,8 +242,15 @@ type Actor struct {
 func Go(options ...Option) (*Actor, error) {
 	// Init with options.
 	act := &Actor{
-		syncActions: make(chan Action),
+		syncActions: make(chan *envelope),
+		wake:        make(chan struct{}, 1),
+		drainc:      make(chan struct{}),
+		done:        make(chan struct{}),
+		signal:      fuse.NewSignal(),
+		repairLog:   fuse.NewRepairLog(),
+		stats:       newStats(),
 	}
+	act.signal.Notify(fuse.Starting)
 	act.works.Store(true)
 	for _, option := range options {
 		if err := option(act); err != nil {
@@ -82,14 +261,16 @@ func Go(options ...Option) (*Actor, error) {
 	if act.ctx == nil {
 		act.ctx, act.cancel = context.WithCancel(context.Background())
 	} else {
+		act.parent = act.ctx
 		act.ctx, act.cancel = context.WithCancel(act.ctx)
 	}
-	if act.asyncActions == nil {
-		act.asyncActions = make(chan Action, DefaultQueueCap)
+	if act.queueCap == 0 {
+		act.queueCap = DefaultQueueCap
 	}
+	act.makeQueues()
 	// Create loop with its options.
 	started := make(chan struct{})
-	go act.backend(started)
+	go act.backend(started, nil)
 	select {
 	case <-started:
 		return act, nil
@@ -107,22 +288,51 @@ func (act *Actor) DoAsync(action Action) error {
 // DoAsyncTimeout send the actor function to the backend and returns
 // when it's queued.
 func (act *Actor) DoAsyncTimeout(action Action, timeout time.Duration) error {
-	act.mu.Lock()
-	if act.err != nil {
-		act.mu.Unlock()
-		return act.err
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return timeoutErr(act.doAsync(ctx, action))
+}
+
+// DoAsyncPriority send the actor function to the lane of the priority
+// and returns when it's queued.
+func (act *Actor) DoAsyncPriority(priority Priority, action Action) error {
+	if priority < PriorityNormal || priority > PriorityLow {
+		return fmt.Errorf("invalid priority: %d", priority)
 	}
-	if !act.works.Load().(bool) {
-		act.mu.Unlock()
-		return fmt.Errorf("actor doesn't work anymore")
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	if err := act.acquire(); err != nil {
+		return err
 	}
-	act.mu.Unlock()
-	select {
-	case act.asyncActions <- action:
-	case <-time.After(timeout):
-		return fmt.Errorf("timeout")
+	defer act.release()
+	return timeoutErr(act.enqueue(ctx, priority, &envelope{action: action}, act.queuePolicy))
+}
+
+// TryDoAsync send the actor function to the backend if it can be
+// queued immediately. Otherwise ErrQueueFull is returned.
+func (act *Actor) TryDoAsync(action Action) error {
+	if err := act.acquire(); err != nil {
+		return err
 	}
-	return nil
+	defer act.release()
+	return act.enqueue(context.Background(), PriorityNormal, &envelope{action: action}, QueueReject)
+}
+
+// DoAsyncContext send the actor function to the backend and returns
+// when it's queued or the context is done. The context passed to the
+// action is cancelled when the caller context is done or the Actor
+// stops. If the caller context is already done when the action is
+// dequeued it won't be executed anymore.
+func (act *Actor) DoAsyncContext(ctx context.Context, action ContextAction) error {
+	return act.doAsync(ctx, func() {
+		if ctx.Err() != nil {
+			// Caller gave up.
+			return
+		}
+		actx, cancel := act.joinContext(ctx)
+		defer cancel()
+		action(actx)
+	})
 }
 
 // DoSync executes the actor function and returns when it's done
@@ -134,79 +344,480 @@ func (act *Actor) DoSync(action Action) error {
 // DoSyncTimeout executes the action and returns when it's done
 // or it has a timeout.
 func (act *Actor) DoSyncTimeout(action Action, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return timeoutErr(act.doSync(ctx, action))
+}
+
+// DoSyncContext executes the action and returns when it's done
+// or the context is done. The context passed to the action is
+// cancelled when the caller context is done or the Actor stops.
+func (act *Actor) DoSyncContext(ctx context.Context, action ContextAction) error {
+	return act.doSync(ctx, func() {
+		actx, cancel := act.joinContext(ctx)
+		defer cancel()
+		action(actx)
+	})
+}
+
+// DoAfter sends the actor function to the backend after the delay.
+// The returned function cancels the scheduled action. It is also
+// cancelled when the Actor stops.
+func (act *Actor) DoAfter(delay time.Duration, action Action) (func(), error) {
+	return act.schedule(delay, false, action)
+}
+
+// DoEvery sends the actor function to the backend in the given
+// interval. The returned function cancels the scheduled action. It
+// is also cancelled when the Actor stops.
+func (act *Actor) DoEvery(interval time.Duration, action Action) (func(), error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid interval: %v", interval)
+	}
+	return act.schedule(interval, true, action)
+}
+
+// Err returns information if the Actor has an error.
+func (act *Actor) Err() error {
 	act.mu.Lock()
-	if act.err != nil {
-		act.mu.Unlock()
-		return act.err
+	defer act.mu.Unlock()
+	return act.err
+}
+
+// Stop terminates the Actor backend.
+func (act *Actor) Stop() {
+	act.mu.Lock()
+	defer act.mu.Unlock()
+	if !act.works.Load().(bool) {
+		// Already stopped.
+		return
 	}
+	act.works.Store(false)
+	act.signal.Notify(fuse.Stopping)
+	act.cancel()
+	act.activate()
+}
+
+// stopWithError terminates the Actor backend with the
+// given error.
+func (act *Actor) stopWithError(err error) {
+	act.mu.Lock()
+	defer act.mu.Unlock()
 	if !act.works.Load().(bool) {
-		act.mu.Unlock()
-		return fmt.Errorf("actor doesn't work anymore")
+		// Already stopped.
+		return
 	}
-	act.mu.Unlock()
+	act.err = err
+	act.works.Store(false)
+	act.signal.Notify(fuse.Stopping)
+	act.cancel()
+	act.activate()
+}
+
+// doAsync sends the action to the backend and returns when
+// it's queued or the context is done.
+func (act *Actor) doAsync(ctx context.Context, action Action) error {
+	return act.doAsyncEnvelope(ctx, &envelope{action: action})
+}
+
+// doAsyncEnvelope sends the envelope to the backend and returns
+// when it's queued or the context is done.
+func (act *Actor) doAsyncEnvelope(ctx context.Context, env *envelope) error {
+	if err := act.acquire(); err != nil {
+		return err
+	}
+	defer act.release()
+	return act.enqueue(ctx, PriorityNormal, env, act.queuePolicy)
+}
+
+// enqueue puts the envelope into the queue of the priority
+// following the given policy.
+func (act *Actor) enqueue(ctx context.Context, priority Priority, env *envelope, policy QueuePolicy) error {
+	env.enqueued = time.Now()
+	queue := act.queue(priority.lane())
+	if policy == QueueBlock {
+		select {
+		case queue <- env:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return nil
+	}
+	for {
+		select {
+		case queue <- env:
+			return nil
+		default:
+		}
+		switch policy {
+		case QueueReject:
+			return ErrQueueFull
+		case QueueDropNewest:
+			env.discard()
+			return nil
+		}
+		// Drop the oldest action and try again.
+		select {
+		case old := <-queue:
+			old.discard()
+		default:
+		}
+	}
+}
+
+// doSync executes the action and returns when it's done or
+// the context is done.
+func (act *Actor) doSync(ctx context.Context, action Action) error {
+	if err := act.acquire(); err != nil {
+		return err
+	}
+	defer act.release()
 	done := make(chan struct{})
-	syncAction := func() {
-		action()
-		close(done)
+	env := &envelope{
+		sync:     true,
+		enqueued: time.Now(),
+		action: func() {
+			action()
+			close(done)
+		},
 	}
-	select {
-	case act.syncActions <- syncAction:
-	case <-time.After(timeout):
-		return fmt.Errorf("timeout")
+	sent, err := act.sendSync(ctx, env)
+	if err != nil {
+		return err
+	}
+	if !sent {
+		if act.reentrancy == ReentrantInline {
+			action()
+			return nil
+		}
+		return ErrReentrant
 	}
 	select {
 	case <-done:
-	case <-time.After(timeout):
-		if !act.works.Load().(bool) {
-			return act.err
+	case <-ctx.Done():
+		if err := act.check(); err != nil {
+			return err
 		}
-		return fmt.Errorf("timeout")
+		return ctx.Err()
 	}
 	return nil
 }
 
-// Err returns information if the Actor has an error.
-func (act *Actor) Err() error {
+// schedule starts a goroutine sending the action to the backend
+// after the duration, repeatedly if wanted.
+func (act *Actor) schedule(d time.Duration, repeat bool, action Action) (func(), error) {
+	if err := act.check(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(act.ctx)
+	var cancelled int32
+	scheduledAction := func() {
+		if atomic.LoadInt32(&cancelled) == 0 {
+			action()
+		}
+	}
+	go func() {
+		defer cancel()
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+				if err := act.doAsync(ctx, scheduledAction); err != nil || !repeat {
+					return
+				}
+				timer.Reset(d)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() {
+		atomic.StoreInt32(&cancelled, 1)
+		cancel()
+	}, nil
+}
+
+// sendSync sends the envelope of a synchronous action to the
+// backend. It returns false if the caller is the running action
+// itself, which would deadlock. As this needs the expensive
+// goroutine ID it is only checked if the action running at the
+// time of the call still runs after the reentrantDelay.
+func (act *Actor) sendSync(ctx context.Context, env *envelope) (bool, error) {
+	select {
+	case act.syncActions <- env:
+		return true, nil
+	default:
+	}
+	runs := atomic.LoadUint64(&act.runs)
+	timer := time.NewTimer(reentrantDelay)
+	defer timer.Stop()
+	check := timer.C
+	for {
+		select {
+		case act.syncActions <- env:
+			return true, nil
+		case <-act.done:
+			return false, act.check()
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-check:
+			check = nil
+			if act.reentrant(runs) {
+				return false, nil
+			}
+		}
+	}
+}
+
+// reentrant checks if the caller runs on the backend goroutine
+// of the Actor inside the action started as the given run.
+func (act *Actor) reentrant(runs uint64) bool {
+	if atomic.LoadInt32(&act.busy) == 0 || atomic.LoadUint64(&act.runs) != runs {
+		// Backend is idle or continued, so caller is another goroutine.
+		return false
+	}
+	return goroutine.ID() == atomic.LoadUint64(&act.backendID)
+}
+
+// check returns an error if the Actor cannot accept
+// actions anymore.
+func (act *Actor) check() error {
 	act.mu.Lock()
 	defer act.mu.Unlock()
-	return act.err
+	return act.checkLocked()
 }
 
-// Stop terminates the Actor backend.
-func (act *Actor) Stop() {
+// checkLocked is check() for callers holding the mutex.
+func (act *Actor) checkLocked() error {
+	if act.err != nil {
+		return act.err
+	}
+	if !act.works.Load().(bool) {
+		return fmt.Errorf("actor doesn't work anymore")
+	}
+	if act.draining {
+		return ErrDraining
+	}
+	return nil
+}
+
+// acquire checks if the Actor accepts actions and registers the
+// caller as pending sender. A passivated Actor is activated.
+func (act *Actor) acquire() error {
 	act.mu.Lock()
 	defer act.mu.Unlock()
-	if !act.works.Load().(bool) {
-		// Already stopped.
+	if err := act.checkLocked(); err != nil {
+		return err
+	}
+	act.activate()
+	act.pending++
+	return nil
+}
+
+// release unregisters a pending sender.
+func (act *Actor) release() {
+	act.mu.Lock()
+	defer act.mu.Unlock()
+	act.pending--
+}
+
+// activate starts a new backend goroutine if the Actor is
+// passivated. The caller must hold the mutex.
+func (act *Actor) activate() {
+	if !act.passivated {
 		return
 	}
-	act.works.Store(false)
-	act.cancel()
+	act.passivated = false
+	act.makeQueues()
+	passivated := act.idle
+	act.idle = nil
+	if act.parent != nil {
+		unwatch(act)
+	}
+	go act.backend(nil, passivated)
 }
 
-// backend runs the goroutine of the Actor.
-func (act *Actor) backend(started chan struct{}) {
-	defer act.finalize()
-	close(started)
+// passivate checks if the Actor is idle and if so releases its
+// queue. The returned channel has to be closed after the backend
+// goroutine is done.
+func (act *Actor) passivate() (chan struct{}, bool) {
+	act.mu.Lock()
+	defer act.mu.Unlock()
+	if act.pending > 0 || act.queueLenLocked() > 0 || act.draining || !act.works.Load().(bool) {
+		return nil, false
+	}
+	act.passivated = true
+	act.asyncActions = [priorities]chan *envelope{}
+	act.idle = make(chan struct{})
+	if act.parent != nil {
+		// Finalize when the parent context is done.
+		watch(act)
+	}
+	return act.idle, true
+}
+
+// makeQueues creates the queue of the normal lane. The queues of
+// the high and low lanes are created with their first action.
+func (act *Actor) makeQueues() {
+	act.asyncActions[normalLane] = make(chan *envelope, act.queueCap)
+}
+
+// queue returns the queue of the lane and creates it if needed.
+func (act *Actor) queue(lane int) chan *envelope {
+	act.mu.Lock()
+	defer act.mu.Unlock()
+	if act.asyncActions[lane] == nil {
+		act.asyncActions[lane] = make(chan *envelope, act.queueCap)
+		// Let the backend know the new queue.
+		select {
+		case act.wake <- struct{}{}:
+		default:
+		}
+	}
+	return act.asyncActions[lane]
+}
+
+// lanes returns the queues of all lanes.
+func (act *Actor) lanes() [priorities]chan *envelope {
+	act.mu.Lock()
+	defer act.mu.Unlock()
+	return act.asyncActions
+}
+
+// queueCapTotal returns the capacity of the queues of all
+// created lanes. The normal lane always counts.
+func (act *Actor) queueCapTotal() int {
+	act.mu.Lock()
+	defer act.mu.Unlock()
+	c := act.queueCap
+	for _, lane := range []int{highLane, lowLane} {
+		c += cap(act.asyncActions[lane])
+	}
+	return c
+}
+
+// queueLen returns the number of queued asynchronous actions.
+func (act *Actor) queueLen() int {
+	act.mu.Lock()
+	defer act.mu.Unlock()
+	return act.queueLenLocked()
+}
+
+// queueLenLocked is queueLen() for callers holding the mutex.
+func (act *Actor) queueLenLocked() int {
+	l := 0
+	for _, queue := range act.asyncActions {
+		l += len(queue)
+	}
+	return l
+}
+
+// joinContext returns a context that is cancelled when the
+// passed one is done or the Actor stops.
+func (act *Actor) joinContext(ctx context.Context) (context.Context, func()) {
+	jctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-act.ctx.Done():
+			cancel()
+		case <-jctx.Done():
+		}
+	}()
+	return jctx, cancel
+}
+
+// StopGracefully terminates the Actor backend after all queued
+// actions are done. New actions are refused. If the context is
+// done before the queue has been drained the Actor is stopped
+// immediately and the number of dropped actions is returned
+// together with the error of the context.
+func (act *Actor) StopGracefully(ctx context.Context) (int, error) {
+	act.mu.Lock()
+	if act.works.Load().(bool) && !act.draining {
+		act.draining = true
+		act.signal.Notify(fuse.Stopping)
+		close(act.drainc)
+		act.activate()
+	}
+	act.mu.Unlock()
+	select {
+	case <-act.done:
+		return 0, nil
+	case <-ctx.Done():
+	}
+	act.Stop()
+	act.mu.Lock()
+	asyncActions := act.asyncActions
+	act.mu.Unlock()
+	dropped := 0
+	for _, queue := range asyncActions {
+		dropped += steal(queue)
+	}
+	return dropped, ctx.Err()
+}
+
+// backend runs the goroutine of the Actor. When activated after a
+// passivation it waits until the passivated one is done.
+func (act *Actor) backend(started, passivated chan struct{}) {
+	if passivated != nil {
+		<-passivated
+	}
+	idle := false
+	defer func() {
+		if !idle {
+			act.finalize()
+		}
+	}()
+	atomic.StoreUint64(&act.backendID, goroutine.ID())
+	act.signal.Notify(fuse.Working)
+	if started != nil {
+		close(started)
+	}
+	if passivated != nil && act.onActivate != nil && act.works.Load().(bool) && act.ctx.Err() == nil {
+		act.onActivate()
+	}
 	for act.works.Load().(bool) {
-		act.work()
+		var done chan struct{}
+		if done, idle = act.work(); idle {
+			if act.onPassivate != nil {
+				act.onPassivate()
+			}
+			close(done)
+			return
+		}
 	}
 }
 
-// work runs the select in a loop, including
-// a possible repairer.
-func (act *Actor) work() {
+// work runs the select in a loop, including a possible repairer.
+// When the Actor passivates it returns true and the channel to
+// close when the backend is done.
+func (act *Actor) work() (done chan struct{}, idle bool) {
 	defer func() {
 		// Check and handle panics!
 		reason := recover()
+		stack := act.panicStack
+		act.panicStack = nil
 		switch {
 		case reason != nil && act.repairer != nil:
 			// Try to repair.
-			err := act.repairer(reason)
+			if stack == nil {
+				stack = debug.Stack()
+			}
+			decision := act.repairer(act.repairLog.Context(reason, stack))
+			err := decision.Err()
+			if err == nil {
+				act.stats.repair()
+			}
 			act.mu.Lock()
 			act.err = err
 			act.works.Store(act.err == nil)
 			act.mu.Unlock()
+			if err == nil {
+				// Backend restarts after the delay.
+				act.sleep(decision.Delay())
+				act.repairLog.Repaired()
+			}
 		case reason != nil && act.repairer == nil:
 			// Accept panic.
 			act.mu.Lock()
@@ -215,26 +826,231 @@ func (act *Actor) work() {
 			act.mu.Unlock()
 		}
 	}()
+	// Prepare idle timer.
+	var timer *time.Timer
+	var idleTimeout <-chan time.Time
+	if act.idleTimeout > 0 {
+		timer = time.NewTimer(act.idleTimeout)
+		defer timer.Stop()
+		idleTimeout = timer.C
+	}
+	last := time.Now()
+	lanes := act.lanes()
 	// Select in loop.
 	for {
+		if act.ctx.Err() != nil {
+			act.works.Store(false)
+			return
+		}
+		// Take newly created lanes.
+		select {
+		case <-act.wake:
+			lanes = act.lanes()
+		default:
+		}
+		// Run queued actions by priority.
+		if env, ok := act.next(lanes); ok {
+			act.run(env)
+			last = time.Now()
+			continue
+		}
+		// Wait for the next action.
 		select {
 		case <-act.ctx.Done():
+			act.works.Store(false)
 			return
-		case action := <-act.asyncActions:
-			action()
-		case action := <-act.syncActions:
-			action()
+		case <-act.wake:
+			lanes = act.lanes()
+		case env := <-lanes[highLane]:
+			act.run(env)
+			last = time.Now()
+		case env := <-lanes[normalLane]:
+			act.run(env)
+			last = time.Now()
+		case env := <-lanes[lowLane]:
+			act.run(env)
+			last = time.Now()
+		case env := <-act.syncActions:
+			act.run(env)
+			last = time.Now()
+		case <-idleTimeout:
+			if remaining := act.idleTimeout - time.Since(last); remaining > 0 {
+				timer.Reset(remaining)
+				continue
+			}
+			if done, idle = act.passivate(); idle {
+				return
+			}
+			timer.Reset(act.idleTimeout)
+		case <-act.drainc:
+			act.drain()
+			act.works.Store(false)
+			return
+		}
+	}
+}
+
+// next returns the next waiting action of the highest lane without
+// blocking. Synchronous actions belong to the normal lane. After
+// starvationLimit actions of a lane in a row a waiting action of a
+// lower lane is preferred.
+func (act *Actor) next(lanes [priorities]chan *envelope) (*envelope, bool) {
+	for _, limited := range []bool{true, false} {
+		for lane := range lanes {
+			if limited && act.streaks[lane] >= starvationLimit {
+				continue
+			}
+			if env, ok := act.receive(lanes, lane); ok {
+				act.streaks[lane]++
+				// Lane has been reached, so higher ones start again.
+				for higher := 0; higher < lane; higher++ {
+					act.streaks[higher] = 0
+				}
+				return env, true
+			}
+		}
+		// Only lanes at their limit have actions.
+		act.streaks = [priorities]int{}
+	}
+	return nil, false
+}
+
+// receive returns a waiting action of the lane without blocking.
+func (act *Actor) receive(lanes [priorities]chan *envelope, lane int) (*envelope, bool) {
+	if lane == normalLane {
+		select {
+		case env := <-act.syncActions:
+			return env, true
+		default:
+		}
+	}
+	select {
+	case env := <-lanes[lane]:
+		return env, true
+	default:
+		return nil, false
+	}
+}
+
+// sleep waits for the duration on the backend goroutine
+// unless the Actor is stopped.
+func (act *Actor) sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-act.ctx.Done():
+	}
+}
+
+// drain runs all queued actions until the queue is empty and
+// no sender accepted before the draining is pending anymore, or
+// until the Actor is stopped.
+func (act *Actor) drain() {
+	for act.ctx.Err() == nil {
+		lanes := act.lanes()
+		if env, ok := act.next(lanes); ok {
+			act.run(env)
+			continue
 		}
+		act.mu.Lock()
+		pending := act.pending
+		act.mu.Unlock()
+		if pending == 0 {
+			// No new senders are accepted, so the queue
+			// only has to be checked a last time.
+			env, ok := act.next(act.lanes())
+			if !ok {
+				return
+			}
+			act.run(env)
+			continue
+		}
+		// Wait for the actions of the pending senders.
+		timer := time.NewTimer(time.Millisecond)
+		select {
+		case <-act.ctx.Done():
+		case env := <-lanes[highLane]:
+			act.run(env)
+		case env := <-lanes[normalLane]:
+			act.run(env)
+		case env := <-lanes[lowLane]:
+			act.run(env)
+		case env := <-act.syncActions:
+			act.run(env)
+		case <-timer.C:
+		}
+		timer.Stop()
 	}
 }
 
 // finalize takes care for a clean loop finalization.
 func (act *Actor) finalize() {
+	act.stats.stop()
 	act.mu.Lock()
-	defer act.mu.Unlock()
-	if act.finalizer != nil {
-		act.err = act.finalizer(act.err)
+	act.signal.Notify(fuse.Stopping)
+	act.cancel()
+	for _, finalizer := range act.finalizers {
+		act.err = finalizer(act.err)
+	}
+	err := act.err
+	monitors := act.monitors
+	exits := act.exits
+	links := act.links
+	futures := act.futures
+	act.monitors = nil
+	act.exits = nil
+	act.links = nil
+	act.futures = nil
+	close(act.done)
+	act.signal.Notify(fuse.Stopped)
+	act.mu.Unlock()
+	// Complete futures of actions not executed anymore.
+	for f := range futures {
+		f.complete(nil, ErrDropped)
+	}
+	// Notify registries, monitors, and linked Actors.
+	for _, exit := range exits {
+		exit()
+	}
+	for _, monitor := range monitors {
+		monitor <- err
+		close(monitor)
+	}
+	for linked := range links {
+		linked.exited(act, err)
+	}
+}
+
+//--------------------
+// PRIVATE HELPER
+//--------------------
+
+// steal removes and discards all actions from the queue
+// and returns their number.
+func steal(queue chan *envelope) int {
+	n := 0
+	for {
+		select {
+		case env := <-queue:
+			env.discard()
+			n++
+		default:
+			return n
+		}
+	}
+}
+
+// timeoutErr maps an exceeded deadline to the timeout
+// error of the timeout based methods.
+func timeoutErr(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("timeout")
 	}
+	return err
 }
 
 // EOF
@@ -14,11 +14,14 @@ package actor_test
 import (
 	"context"
 	"errors"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"tideland.dev/go/audit/asserts"
 	"tideland.dev/go/together/actor"
+	"tideland.dev/go/together/fuse"
 )
 
 //--------------------
@@ -75,6 +78,24 @@ func TestWithContext(t *testing.T) {
 	assert.NoError(act.Err())
 }
 
+// TestContextCancelFinalizes tests the termination and finalization
+// of an Actor after its context has been cancelled.
+func TestContextCancelFinalizes(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	ctx, cancel := context.WithCancel(context.Background())
+	finalized := make(chan struct{})
+	act, err := actor.Go(actor.WithContext(ctx), actor.WithFinalizer(func(err error) error {
+		defer close(finalized)
+		return err
+	}))
+	assert.OK(err)
+
+	cancel()
+	<-finalized
+
+	assert.ErrorMatch(act.DoSync(func() {}), "actor doesn't work anymore")
+}
+
 // TestSync tests synchronous calls.
 func TestSync(t *testing.T) {
 	assert := asserts.NewTesting(t, asserts.FailStop)
@@ -121,6 +142,69 @@ func TestTimeout(t *testing.T) {
 	act.Stop()
 }
 
+// TestSyncContext tests synchronous calls with a context.
+func TestSyncContext(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	// Scenario: Action finishes in time.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	counter := 0
+	err = act.DoSyncContext(ctx, func(actx context.Context) {
+		counter++
+	})
+	assert.OK(err)
+	assert.Equal(counter, 1)
+
+	// Scenario: Caller context exceeds, action gets notified.
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	cancelled := make(chan struct{})
+	err = act.DoSyncContext(ctx, func(actx context.Context) {
+		<-actx.Done()
+		close(cancelled)
+	})
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	<-cancelled
+}
+
+// TestAsyncContext tests asynchronous calls with a context.
+func TestAsyncContext(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+
+	// Scenario: Action is skipped if the caller gave up.
+	ctx, cancel := context.WithCancel(context.Background())
+	blocker := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		<-blocker
+	}))
+	executed := false
+	assert.OK(act.DoAsyncContext(ctx, func(actx context.Context) {
+		executed = true
+	}))
+	cancel()
+	close(blocker)
+	assert.OK(act.DoSync(func() {}))
+	assert.False(executed)
+
+	// Scenario: Action context is cancelled when the actor stops.
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	assert.OK(act.DoAsyncContext(context.Background(), func(actx context.Context) {
+		close(started)
+		<-actx.Done()
+		close(cancelled)
+	}))
+	<-started
+	act.Stop()
+	<-cancelled
+}
+
 // TestAsyncWithQueueCap tests running multiple calls asynchronously.
 func TestAsyncWithQueueCap(t *testing.T) {
 	assert := asserts.NewTesting(t, asserts.FailStop)
@@ -162,6 +246,246 @@ func TestAsyncWithQueueCap(t *testing.T) {
 	act.Stop()
 }
 
+// TestQueuePolicies tests the different handlings of full queues.
+func TestQueuePolicies(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	// fill blocks the actor and fills its queue with actions
+	// appending their index to the returned slice.
+	fill := func(act *actor.Actor) (chan struct{}, *[]int) {
+		started := make(chan struct{})
+		blocker := make(chan struct{})
+		assert.OK(act.DoAsync(func() {
+			close(started)
+			<-blocker
+		}))
+		<-started
+		done := []int{}
+		for i := 0; i < actor.DefaultQueueCap; i++ {
+			i := i
+			assert.OK(act.TryDoAsync(func() {
+				done = append(done, i)
+			}))
+		}
+		return blocker, &done
+	}
+	// processed waits until all queued actions are done
+	// and returns their indexes.
+	processed := func(act *actor.Actor, done *[]int) []int {
+		var indexes []int
+		for len(indexes) < actor.DefaultQueueCap {
+			assert.OK(act.DoSync(func() {
+				indexes = append([]int{}, *done...)
+			}))
+		}
+		return indexes
+	}
+
+	// Scenario: Block.
+	act, err := actor.Go()
+	assert.OK(err)
+	blocker, _ := fill(act)
+	assert.ErrorMatch(act.DoAsyncTimeout(func() {}, 50*time.Millisecond), "timeout")
+	assert.Equal(act.TryDoAsync(func() {}), actor.ErrQueueFull)
+	close(blocker)
+	act.Stop()
+
+	// Scenario: Reject.
+	act, err = actor.Go(actor.WithQueuePolicy(actor.QueueReject))
+	assert.OK(err)
+	blocker, _ = fill(act)
+	assert.True(errors.Is(act.DoAsync(func() {}), actor.ErrQueueFull))
+	close(blocker)
+	act.Stop()
+
+	// Scenario: Drop newest.
+	act, err = actor.Go(actor.WithQueuePolicy(actor.QueueDropNewest))
+	assert.OK(err)
+	blocker, done := fill(act)
+	assert.OK(act.DoAsync(func() {
+		*done = append(*done, -1)
+	}))
+	close(blocker)
+	indexes := processed(act, done)
+	assert.Length(indexes, actor.DefaultQueueCap)
+	assert.Equal(indexes[actor.DefaultQueueCap-1], actor.DefaultQueueCap-1)
+	act.Stop()
+
+	// Scenario: Drop oldest.
+	act, err = actor.Go(actor.WithQueuePolicy(actor.QueueDropOldest))
+	assert.OK(err)
+	blocker, done = fill(act)
+	assert.OK(act.DoAsync(func() {
+		*done = append(*done, -1)
+	}))
+	close(blocker)
+	indexes = processed(act, done)
+	assert.Length(indexes, actor.DefaultQueueCap)
+	assert.Equal(indexes[0], 1)
+	assert.Equal(indexes[actor.DefaultQueueCap-1], -1)
+	act.Stop()
+
+	// Scenario: Invalid policy.
+	_, err = actor.Go(actor.WithQueuePolicy(actor.QueuePolicy(99)))
+	assert.ErrorMatch(err, "invalid queue policy: 99")
+}
+
+// TestDoAfter tests the delayed execution of actions.
+func TestDoAfter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	done := make(chan time.Time, 1)
+	start := time.Now()
+	_, err = act.DoAfter(50*time.Millisecond, func() {
+		done <- time.Now()
+	})
+	assert.OK(err)
+	assert.True((<-done).Sub(start) >= 50*time.Millisecond)
+
+	cancel, err := act.DoAfter(50*time.Millisecond, func() {
+		done <- time.Now()
+	})
+	assert.OK(err)
+	cancel()
+	select {
+	case <-done:
+		assert.Fail("cancelled action executed")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestDoEvery tests the periodic execution of actions and their
+// ending when the actor stops.
+func TestDoEvery(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+
+	ticks := make(chan struct{}, 10)
+	cancel, err := act.DoEvery(10*time.Millisecond, func() {
+		ticks <- struct{}{}
+	})
+	assert.OK(err)
+	for i := 0; i < 3; i++ {
+		<-ticks
+	}
+	cancel()
+	_, err = act.DoEvery(0, func() {})
+	assert.ErrorMatch(err, "invalid interval: 0s")
+
+	_, err = act.DoEvery(10*time.Millisecond, func() {
+		ticks <- struct{}{}
+	})
+	assert.OK(err)
+	<-ticks
+	act.Stop()
+	time.Sleep(20 * time.Millisecond)
+	for len(ticks) > 0 {
+		<-ticks
+	}
+	select {
+	case <-ticks:
+		assert.Fail("action executed after stop")
+	case <-time.After(50 * time.Millisecond):
+	}
+	_, err = act.DoAfter(time.Millisecond, func() {})
+	assert.ErrorMatch(err, "actor doesn't work anymore")
+}
+
+// TestStopGracefully tests the draining of the queue when stopping.
+func TestStopGracefully(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	finalized := false
+	act, err := actor.Go(actor.WithFinalizer(func(err error) error {
+		finalized = true
+		return err
+	}))
+	assert.OK(err)
+
+	counter := 0
+	for i := 0; i < 10; i++ {
+		assert.OK(act.DoAsync(func() {
+			time.Sleep(5 * time.Millisecond)
+			counter++
+		}))
+	}
+	dropped, err := act.StopGracefully(context.Background())
+	assert.OK(err)
+	assert.Equal(dropped, 0)
+	assert.Equal(counter, 10)
+	assert.True(finalized)
+	assert.ErrorMatch(act.DoAsync(func() {}), "actor doesn't work anymore")
+}
+
+// TestStopGracefullyTimeout tests the reporting of dropped actions
+// when the draining exceeds the context.
+func TestStopGracefullyTimeout(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+
+	started := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+	}))
+	<-started
+	for i := 0; i < 10; i++ {
+		assert.OK(act.DoAsync(func() {
+			time.Sleep(100 * time.Millisecond)
+		}))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	dropped, err := act.StopGracefully(ctx)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(dropped, 10)
+}
+
+// TestReentrancy tests the handling of synchronous actions sent
+// from inside an action of the same Actor.
+func TestReentrancy(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	// Scenario: Error.
+	act, err := actor.Go()
+	assert.OK(err)
+	var inner error
+	assert.OK(act.DoSync(func() {
+		inner = act.DoSync(func() {})
+	}))
+	assert.Equal(inner, actor.ErrReentrant)
+	act.Stop()
+
+	// Scenario: Inline.
+	act, err = actor.Go(actor.WithReentrancy(actor.ReentrantInline))
+	assert.OK(err)
+	counter := 0
+	assert.OK(act.DoSync(func() {
+		counter++
+		inner = act.DoSync(func() {
+			counter++
+		})
+	}))
+	assert.NoError(inner)
+	assert.Equal(counter, 2)
+	act.Stop()
+
+	// Scenario: Other goroutine waiting for a busy Actor.
+	act, err = actor.Go()
+	assert.OK(err)
+	started := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		close(started)
+		time.Sleep(20 * time.Millisecond)
+	}))
+	<-started
+	assert.OK(act.DoSync(func() {}))
+	act.Stop()
+}
+
 // TestRepairerOK tests handling panics successfully.
 func TestRepairerOK(t *testing.T) {
 	assert := asserts.NewTesting(t, asserts.FailStop)
@@ -224,4 +548,215 @@ func TestRepairerError(t *testing.T) {
 	assert.ErrorMatch(act.Err(), "ouch")
 }
 
+// TestIdlePassivation tests the passivation of an idle Actor
+// and its transparent activation.
+func TestIdlePassivation(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	passivated := make(chan struct{}, 10)
+	activated := make(chan struct{}, 10)
+	_, err := actor.Go(actor.WithIdleTimeout(0))
+	assert.ErrorMatch(err, "invalid idle timeout: 0s")
+	act, err := actor.Go(
+		actor.WithIdleTimeout(20*time.Millisecond),
+		actor.WithPassivationHooks(func() {
+			passivated <- struct{}{}
+		}, func() {
+			activated <- struct{}{}
+		}),
+	)
+	assert.OK(err)
+
+	counter := 0
+	assert.OK(act.DoSync(func() {
+		counter++
+	}))
+	<-passivated
+	stats := act.Stats()
+	assert.Equal(stats.QueueLen, 0)
+	assert.Equal(stats.QueueCap, actor.DefaultQueueCap)
+
+	// Activate by asynchronous and synchronous actions.
+	assert.OK(act.DoAsync(func() {
+		counter++
+	}))
+	<-activated
+	<-passivated
+	assert.OK(act.DoSync(func() {
+		counter++
+	}))
+	<-activated
+	assert.OK(act.DoSync(func() {
+		assert.Equal(counter, 3)
+	}))
+
+	// Stop a passivated Actor.
+	<-passivated
+	act.Stop()
+	assert.NoError(<-act.Monitor())
+	assert.Length(activated, 0)
+
+	// Cancel the context of a passivated Actor.
+	ctx, cancel := context.WithCancel(context.Background())
+	act, err = actor.Go(
+		actor.WithContext(ctx),
+		actor.WithIdleTimeout(20*time.Millisecond),
+		actor.WithPassivationHooks(func() {
+			passivated <- struct{}{}
+		}, func() {
+			activated <- struct{}{}
+		}),
+	)
+	assert.OK(err)
+	<-passivated
+	cancel()
+	assert.OK(act.Wait(fuse.Stopped, time.Second))
+	assert.Length(activated, 0)
+}
+
+// TestIdlePassivationGoroutines tests that passivated Actors
+// release their goroutines, also with a parent context.
+func TestIdlePassivationGoroutines(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	before := runtime.NumGoroutine()
+	passivated := make(chan struct{}, 200)
+	var acts []*actor.Actor
+	for i := 0; i < 200; i++ {
+		options := []actor.Option{
+			actor.WithIdleTimeout(10 * time.Millisecond),
+			actor.WithPassivationHooks(func() {
+				passivated <- struct{}{}
+			}, nil),
+		}
+		if i%2 == 0 {
+			options = append(options, actor.WithContext(ctx))
+		}
+		act, err := actor.Go(options...)
+		assert.OK(err)
+		acts = append(acts, act)
+	}
+	for i := 0; i < 200; i++ {
+		<-passivated
+	}
+	// Backend goroutines may still be returning.
+	assert.Retry(func() bool {
+		return runtime.NumGoroutine()-before < 10
+	}, 100, 10*time.Millisecond)
+
+	cancel()
+	for i, act := range acts {
+		if i%2 == 0 {
+			assert.OK(act.Wait(fuse.Stopped, time.Second))
+		} else {
+			act.Stop()
+		}
+	}
+}
+
+// TestPriorities tests the order of actions with different priorities.
+func TestPriorities(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	var order []string
+	blocked, release := make(chan struct{}), make(chan struct{})
+	block := func() {
+		assert.OK(act.DoAsync(func() {
+			close(blocked)
+			<-release
+		}))
+		<-blocked
+	}
+	add := func(p actor.Priority, id string) {
+		assert.OK(act.DoAsyncPriority(p, func() {
+			order = append(order, id)
+		}))
+	}
+	wait := func() {
+		done := make(chan struct{})
+		assert.OK(act.DoAsyncPriority(actor.PriorityLow, func() {
+			close(done)
+		}))
+		close(release)
+		<-done
+	}
+	assert.ErrorMatch(act.DoAsyncPriority(actor.Priority(99), func() {}), "invalid priority: 99")
+	var zero actor.Priority
+	assert.Equal(zero, actor.PriorityNormal)
+
+	// Lane created while the backend waits.
+	done := make(chan struct{})
+	assert.OK(act.DoAsyncPriority(actor.PriorityHigh, func() {
+		close(done)
+	}))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		assert.Fail("action of new lane not run")
+	}
+	assert.Equal(act.Stats().QueueCap, 2*actor.DefaultQueueCap)
+
+	block()
+	for i := 0; i < 3; i++ {
+		add(actor.PriorityLow, "L")
+		add(actor.PriorityNormal, "N")
+		add(actor.PriorityHigh, "H")
+	}
+	wait()
+	assert.Equal(strings.Join(order, ""), "HHHNNNLLL")
+	assert.Equal(act.Stats().QueueCap, 3*actor.DefaultQueueCap)
+
+	// Lower lanes don't starve.
+	order = nil
+	blocked, release = make(chan struct{}), make(chan struct{})
+	block()
+	add(actor.PriorityLow, "L")
+	add(actor.PriorityLow, "L")
+	for i := 0; i < 40; i++ {
+		add(actor.PriorityHigh, "H")
+	}
+	wait()
+	expected := strings.Repeat("H", 16) + "L" + strings.Repeat("H", 16) + "L" + strings.Repeat("H", 8)
+	assert.Equal(strings.Join(order, ""), expected)
+}
+
+// TestContextRepairer tests the repair context and the decisions
+// of a context repairer.
+func TestContextRepairer(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	var contexts []fuse.RepairContext
+	repairer := func(rctx fuse.RepairContext) fuse.Decision {
+		contexts = append(contexts, rctx)
+		if rctx.Repairs == 1 {
+			return fuse.GiveUp(errors.New("ouch"))
+		}
+		return fuse.RestartAfter(10 * time.Millisecond)
+	}
+	act, err := actor.Go(
+		actor.WithContextRepairer(repairer),
+		actor.WithInterceptors(func(info *actor.ActionInfo, next actor.Action) {
+			next()
+		}),
+	)
+	assert.OK(err)
+
+	for i := 0; i < 2; i++ {
+		assert.OK(act.DoAsync(func() {
+			panic("bam")
+		}))
+	}
+	assert.OK(act.Wait(fuse.Stopped, time.Second))
+	assert.ErrorMatch(act.Err(), "ouch")
+	assert.Length(contexts, 2)
+	for i, rctx := range contexts {
+		assert.Equal(rctx.Reason, "bam")
+		assert.Equal(rctx.Repairs, i)
+		assert.Contains("actor_test.go", string(rctx.Stack))
+	}
+	assert.Equal(act.Stats().Repaired, uint64(1))
+}
+
 // EOF
@@ -52,8 +52,69 @@
 //        c.act.Stop()
 //    }
 //
+// Request-scoped deadlines and cancellations can be passed with the
+// DoAsyncContext() and DoSyncContext() methods. Their actions receive a
+// context that is cancelled when the caller gives up or the actor stops.
+//
+// Actions returning a result can be queued with DoFuture(). The returned
+// Future allows to collect the result later, e.g. after fanning out work
+// to multiple actors. If the action is dropped by the queue policy or the
+// actor terminates before running it the Future returns ErrDropped.
+//
 // Different options for the constructor allow to pass a context for stopping,
-// how many actions are queued, and how panics in actions shall be handled.
+// how many actions are queued, how full queues are handled, and how panics in
+// actions shall be handled. TryDoAsync() never blocks and returns ErrQueueFull
+// if an action cannot be queued immediately.
+//
+// A repairer set with WithContextRepairer() receives a fuse.RepairContext
+// describing the panic and the former repairs. Its fuse.Decision lets the
+// actor continue, continue after a delay, or terminate.
+//
+// WithIdleTimeout() lets an actor without actions for the given time end its
+// goroutine and release its queue. The next action starts it again. Hooks set
+// with WithPassivationHooks() are called on passivation and activation.
+//
+// DoAsyncPriority() queues actions in high, normal, or low priority lanes.
+// Higher lanes are processed first, but after a number of actions in a row a
+// waiting action of a lower lane is taken to avoid starvation. DoAsync() and
+// synchronous actions use the normal lane.
+//
+// Synchronous calls from inside an action to the same actor would deadlock.
+// They are detected and return ErrReentrant or, if configured with
+// WithReentrancy(), are executed inline.
+//
+// Interceptors passed with WithInterceptors() wrap the execution of each
+// action, e.g. for logging, tracing, or the detection of slow actions.
+//
+// DoAfter() and DoEvery() schedule actions to be run once after a delay or
+// periodically on the actor's goroutine. They end when the actor stops.
+//
+// An actor implements fuse.Signaler. Wait(fuse.Stopped, timeout) or
+// Done(fuse.Stopped) allow to wait until it has been finalized.
+//
+// Monitor() returns a channel delivering the final error of an actor after
+// its finalizers have been called. Actors connected with Link() are stopped
+// when a linked one terminates with an error, or notified via a LinkHandler.
+//
+// A Registry allows to look up actors by name. Registered actors are removed
+// automatically when they terminate. Name conflicts are reported as
+// *NameConflictError.
+//
+// Stats() returns a snapshot of runtime statistics like the queue length,
+// the number of processed actions, histograms of queueing delay and
+// execution time, repaired panics, and uptime.
+//
+// A Receiver is a message-based actor. Messages passed with Send() are
+// handled by its current Receive function. Handlers can switch it with
+// Become() to implement state machines, and defer messages with Stash()
+// until UnstashAll() is called in a state able to handle them.
+//
+// A Pool runs multiple actors and routes the actions to them in turn, to
+// the least loaded one, or by a consistent hash of a key. The latter keeps
+// the order of all actions with the same key.
+//
+// Stop() terminates an actor immediately while StopGracefully() refuses new
+// actions but runs all queued ones before the finalizer is called.
 package actor // import "tideland.dev/go/together/actor"
 
 // EOF
@@ -0,0 +1,158 @@
+// Tideland Go Together - Actor
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/together/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+//--------------------
+// FUNCTION TYPES
+//--------------------
+
+// FutureAction defines the signature of an actor action
+// returning a result.
+type FutureAction func() (interface{}, error)
+
+//--------------------
+// FUTURE
+//--------------------
+
+// Future is the handle to the result of an action queued
+// with DoFuture(). It allows to collect the result later.
+type Future struct {
+	mu    sync.Mutex
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// newFuture creates an unfinished future.
+func newFuture() *Future {
+	return &Future{
+		done: make(chan struct{}),
+	}
+}
+
+// Done returns a channel that is closed when the result
+// of the action is available.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the result of the action is available or
+// the context is done. In the latter case the error of the
+// context is returned.
+func (f *Future) Wait(ctx context.Context) (interface{}, error) {
+	select {
+	case <-f.done:
+		return f.Result()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Result returns the result of the action without blocking. If
+// the action is not yet done an error is returned.
+func (f *Future) Result() (interface{}, error) {
+	select {
+	case <-f.done:
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.value, f.err
+	default:
+		return nil, fmt.Errorf("future not yet done")
+	}
+}
+
+// run executes the action and sets the result. A panic is
+// stored as error and raised again for the repairer.
+func (f *Future) run(action FutureAction) {
+	completed := false
+	defer func() {
+		if !completed {
+			reason := recover()
+			f.complete(nil, fmt.Errorf("future action panic: %v", reason))
+			panic(reason)
+		}
+	}()
+	value, err := action()
+	completed = true
+	f.complete(value, err)
+}
+
+// complete sets the result and signals it. Only the first
+// result counts.
+func (f *Future) complete(value interface{}, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	select {
+	case <-f.done:
+		return
+	default:
+	}
+	f.value = value
+	f.err = err
+	close(f.done)
+}
+
+//--------------------
+// ACTOR
+//--------------------
+
+// DoFuture sends the action to the backend and returns a Future
+// when it's queued. The result of the action can be retrieved
+// via the Future later. If the action is dropped by the queue
+// policy or the termination of the Actor the Future returns
+// ErrDropped.
+func (act *Actor) DoFuture(action FutureAction) (*Future, error) {
+	f := newFuture()
+	act.addFuture(f)
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	if err := act.doAsyncEnvelope(ctx, &envelope{
+		action: func() {
+			defer act.removeFuture(f)
+			f.run(action)
+		},
+		drop: func() {
+			act.removeFuture(f)
+			f.complete(nil, ErrDropped)
+		},
+	}); err != nil {
+		act.removeFuture(f)
+		return nil, timeoutErr(err)
+	}
+	return f, nil
+}
+
+// addFuture registers the future for its completion in case
+// of the termination of the Actor.
+func (act *Actor) addFuture(f *Future) {
+	act.mu.Lock()
+	defer act.mu.Unlock()
+	if act.futures == nil {
+		act.futures = make(map[*Future]struct{})
+	}
+	act.futures[f] = struct{}{}
+}
+
+// removeFuture unregisters the future.
+func (act *Actor) removeFuture(f *Future) {
+	act.mu.Lock()
+	defer act.mu.Unlock()
+	delete(act.futures, f)
+}
+
+// EOF
@@ -0,0 +1,196 @@
+// Tideland Go Together - Actor - Unit Tests
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/together/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestFutureOK tests collecting the results of multiple futures.
+func TestFutureOK(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	actA, err := actor.Go()
+	assert.OK(err)
+	defer actA.Stop()
+	actB, err := actor.Go()
+	assert.OK(err)
+	defer actB.Stop()
+
+	fA, err := actA.DoFuture(func() (interface{}, error) {
+		time.Sleep(50 * time.Millisecond)
+		return 1, nil
+	})
+	assert.OK(err)
+	fB, err := actB.DoFuture(func() (interface{}, error) {
+		time.Sleep(50 * time.Millisecond)
+		return 2, nil
+	})
+	assert.OK(err)
+
+	_, err = fA.Result()
+	assert.ErrorMatch(err, "future not yet done")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	vA, err := fA.Wait(ctx)
+	assert.OK(err)
+	vB, err := fB.Wait(ctx)
+	assert.OK(err)
+	assert.Equal(vA.(int)+vB.(int), 3)
+
+	<-fA.Done()
+	vA, err = fA.Result()
+	assert.OK(err)
+	assert.Equal(vA, 1)
+}
+
+// TestFutureError tests futures returning an error or exceeding
+// the waiting context.
+func TestFutureError(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	f, err := act.DoFuture(func() (interface{}, error) {
+		return nil, errors.New("ouch")
+	})
+	assert.OK(err)
+	_, err = f.Wait(context.Background())
+	assert.ErrorMatch(err, "ouch")
+
+	f, err = act.DoFuture(func() (interface{}, error) {
+		time.Sleep(time.Second)
+		return nil, nil
+	})
+	assert.OK(err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = f.Wait(ctx)
+	assert.ErrorMatch(err, ".*deadline exceeded.*")
+}
+
+// TestFuturePanic tests the result of a future if its action
+// panics.
+func TestFuturePanic(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithRepairer(func(reason interface{}) error {
+		return nil
+	}))
+	assert.OK(err)
+	defer act.Stop()
+
+	f, err := act.DoFuture(func() (interface{}, error) {
+		panic("bam")
+	})
+	assert.OK(err)
+	_, err = f.Wait(context.Background())
+	assert.ErrorMatch(err, "future action panic: bam")
+	assert.NoError(act.DoSync(func() {}))
+}
+
+// TestFutureDropped tests the completion of futures whose actions
+// are dropped by the queue policy or the termination of the Actor.
+func TestFutureDropped(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// block blocks the actor until the returned channel is closed.
+	block := func(act *actor.Actor) chan struct{} {
+		started := make(chan struct{})
+		blocker := make(chan struct{})
+		assert.OK(act.DoAsync(func() {
+			close(started)
+			<-blocker
+		}))
+		<-started
+		return blocker
+	}
+	action := func() (interface{}, error) {
+		return 1, nil
+	}
+
+	// Scenario: Drop oldest.
+	act, err := actor.Go(actor.WithQueuePolicy(actor.QueueDropOldest))
+	assert.OK(err)
+	blocker := block(act)
+	oldest, err := act.DoFuture(action)
+	assert.OK(err)
+	for i := 1; i < actor.DefaultQueueCap; i++ {
+		assert.OK(act.TryDoAsync(func() {}))
+	}
+	newest, err := act.DoFuture(action)
+	assert.OK(err)
+	_, err = oldest.Wait(ctx)
+	assert.Equal(err, actor.ErrDropped)
+	close(blocker)
+	v, err := newest.Wait(ctx)
+	assert.OK(err)
+	assert.Equal(v, 1)
+	act.Stop()
+
+	// Scenario: Drop newest.
+	act, err = actor.Go(actor.WithQueuePolicy(actor.QueueDropNewest))
+	assert.OK(err)
+	blocker = block(act)
+	for i := 0; i < actor.DefaultQueueCap; i++ {
+		assert.OK(act.TryDoAsync(func() {}))
+	}
+	newest, err = act.DoFuture(action)
+	assert.OK(err)
+	_, err = newest.Wait(ctx)
+	assert.Equal(err, actor.ErrDropped)
+	close(blocker)
+	act.Stop()
+
+	// Scenario: Stop.
+	act, err = actor.Go()
+	assert.OK(err)
+	blocker = block(act)
+	f, err := act.DoFuture(action)
+	assert.OK(err)
+	act.Stop()
+	close(blocker)
+	_, err = f.Wait(ctx)
+	assert.Equal(err, actor.ErrDropped)
+
+	// Scenario: Stop gracefully with timeout.
+	act, err = actor.Go()
+	assert.OK(err)
+	blocker = block(act)
+	f, err = act.DoFuture(action)
+	assert.OK(err)
+	sctx, scancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer scancel()
+	dropped, err := act.StopGracefully(sctx)
+	assert.ErrorMatch(err, ".*deadline exceeded.*")
+	assert.Equal(dropped, 1)
+	_, err = f.Wait(ctx)
+	assert.Equal(err, actor.ErrDropped)
+	close(blocker)
+
+	// Scenario: Terminated Actor.
+	_, err = act.DoFuture(action)
+	assert.ErrorMatch(err, "actor doesn't work anymore")
+}
+
+// EOF
@@ -0,0 +1,86 @@
+// Tideland Go Together - Actor
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/together/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+//--------------------
+// INTERCEPTOR
+//--------------------
+
+// ActionInfo describes an action passed to the interceptors. Duration
+// and Panic are set after the action returned.
+type ActionInfo struct {
+	Sync     bool
+	Enqueued time.Time
+	Started  time.Time
+	Duration time.Duration
+	Panic    interface{}
+}
+
+// Interceptor wraps the execution of actions, e.g. for logging or
+// tracing. It has to call next to execute the action. A panic of
+// the action is recovered and passed in the info, it will be raised
+// again after all interceptors returned.
+type Interceptor func(info *ActionInfo, next Action)
+
+//--------------------
+// ACTOR
+//--------------------
+
+// run executes the action of the envelope wrapped by
+// the interceptors.
+func (act *Actor) run(env *envelope) {
+	atomic.AddUint64(&act.runs, 1)
+	atomic.StoreInt32(&act.busy, 1)
+	defer atomic.StoreInt32(&act.busy, 0)
+	started := time.Now()
+	defer func() {
+		act.stats.processed(env.sync, started.Sub(env.enqueued), time.Since(started))
+	}()
+	if len(act.interceptors) == 0 {
+		env.action()
+		return
+	}
+	info := &ActionInfo{
+		Sync:     env.sync,
+		Enqueued: env.enqueued,
+	}
+	next := func() {
+		defer func() {
+			info.Duration = time.Since(info.Started)
+			info.Panic = recover()
+			if info.Panic != nil {
+				act.panicStack = debug.Stack()
+			}
+		}()
+		info.Started = time.Now()
+		env.action()
+	}
+	for i := len(act.interceptors) - 1; i >= 0; i-- {
+		interceptor := act.interceptors[i]
+		inner := next
+		next = func() {
+			interceptor(info, inner)
+		}
+	}
+	next()
+	if info.Panic != nil {
+		panic(info.Panic)
+	}
+}
+
+// EOF
@@ -0,0 +1,75 @@
+// Tideland Go Together - Actor - Unit Tests
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/together/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestInterceptors tests the wrapping of actions by interceptors.
+func TestInterceptors(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	calls := []string{}
+	infos := make(chan actor.ActionInfo, 10)
+	outer := func(info *actor.ActionInfo, next actor.Action) {
+		calls = append(calls, "outer-before")
+		next()
+		calls = append(calls, "outer-after")
+		infos <- *info
+	}
+	inner := func(info *actor.ActionInfo, next actor.Action) {
+		calls = append(calls, "inner-before")
+		next()
+		calls = append(calls, "inner-after")
+	}
+	repaired := make(chan interface{}, 1)
+	act, err := actor.Go(
+		actor.WithInterceptors(outer, inner),
+		actor.WithRepairer(func(reason interface{}) error {
+			repaired <- reason
+			return nil
+		}),
+	)
+	assert.OK(err)
+	defer act.Stop()
+
+	// Synchronous action.
+	assert.OK(act.DoSync(func() {
+		calls = append(calls, "action")
+		time.Sleep(10 * time.Millisecond)
+	}))
+	info := <-infos
+	assert.True(info.Sync)
+	assert.False(info.Started.Before(info.Enqueued))
+	assert.True(info.Duration >= 10*time.Millisecond)
+	assert.Nil(info.Panic)
+	assert.Equal(calls, []string{"outer-before", "inner-before", "action", "inner-after", "outer-after"})
+
+	// Asynchronous action with panic.
+	assert.OK(act.DoAsync(func() {
+		panic("bam")
+	}))
+	info = <-infos
+	assert.False(info.Sync)
+	assert.Equal(info.Panic, "bam")
+	assert.Equal(<-repaired, "bam")
+}
+
+// EOF
@@ -0,0 +1,156 @@
+// Tideland Go Together - Actor
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/together/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"time"
+
+	"tideland.dev/go/together/fuse"
+)
+
+//--------------------
+// STATUS
+//--------------------
+
+// Status implements fuse.Signaler. The Actor is Working as long as
+// its backend runs, Stopping when it has been told to stop, and
+// Stopped after the finalizers have been called.
+func (act *Actor) Status() fuse.Status {
+	return act.signal.Status()
+}
+
+// Done implements fuse.Signaler. Done(fuse.Stopped) returns a channel
+// closed after the finalization of the Actor.
+func (act *Actor) Done(status fuse.Status) <-chan struct{} {
+	return act.signal.Done(status)
+}
+
+// Wait implements fuse.Signaler.
+func (act *Actor) Wait(status fuse.Status, timeout time.Duration) error {
+	return act.signal.Wait(status, timeout)
+}
+
+//--------------------
+// MONITORING
+//--------------------
+
+// Monitor returns a channel delivering the final error of the Actor
+// after the finalizers have been called. Afterwards the channel is
+// closed. If the Actor already terminated the error is delivered
+// immediately.
+func (act *Actor) Monitor() <-chan error {
+	monitor := make(chan error, 1)
+	act.mu.Lock()
+	defer act.mu.Unlock()
+	select {
+	case <-act.done:
+		monitor <- act.err
+		close(monitor)
+	default:
+		act.monitors = append(act.monitors, monitor)
+	}
+	return monitor
+}
+
+// addExit adds a function called after termination if the Actor
+// hasn't terminated yet. The returned ID allows to remove it.
+func (act *Actor) addExit(exit func()) (uint64, bool) {
+	act.mu.Lock()
+	defer act.mu.Unlock()
+	select {
+	case <-act.done:
+		return 0, false
+	default:
+	}
+	if act.exits == nil {
+		act.exits = make(map[uint64]func())
+	}
+	act.exitID++
+	act.exits[act.exitID] = exit
+	return act.exitID, true
+}
+
+// removeExit removes the function with the ID.
+func (act *Actor) removeExit(id uint64) {
+	act.mu.Lock()
+	defer act.mu.Unlock()
+	delete(act.exits, id)
+}
+
+//--------------------
+// LINKING
+//--------------------
+
+// Link connects the Actor with another one in both directions. If one
+// of them terminates the other one is notified via its LinkHandler. If
+// it has none it is stopped when the terminated one has an error.
+func (act *Actor) Link(other *Actor) error {
+	if act == other {
+		return fmt.Errorf("actor cannot link itself")
+	}
+	if !act.addLink(other) {
+		return fmt.Errorf("actor doesn't work anymore")
+	}
+	if !other.addLink(act) {
+		act.removeLink(other)
+		return fmt.Errorf("linked actor doesn't work anymore")
+	}
+	return nil
+}
+
+// Unlink removes the connection between the Actor and the other one.
+func (act *Actor) Unlink(other *Actor) {
+	act.removeLink(other)
+	other.removeLink(act)
+}
+
+// addLink adds the other Actor to the links if the Actor
+// hasn't terminated yet.
+func (act *Actor) addLink(other *Actor) bool {
+	act.mu.Lock()
+	defer act.mu.Unlock()
+	select {
+	case <-act.done:
+		return false
+	default:
+	}
+	if act.links == nil {
+		act.links = make(map[*Actor]struct{})
+	}
+	act.links[other] = struct{}{}
+	return true
+}
+
+// removeLink removes the other Actor from the links.
+func (act *Actor) removeLink(other *Actor) {
+	act.mu.Lock()
+	defer act.mu.Unlock()
+	delete(act.links, other)
+}
+
+// exited is called by a linked Actor when it terminated.
+func (act *Actor) exited(linked *Actor, err error) {
+	act.removeLink(linked)
+	if act.linkHandler != nil {
+		// Ignore error, Actor may have terminated too.
+		_ = act.DoAsync(func() {
+			act.linkHandler(err)
+		})
+		return
+	}
+	if err != nil {
+		act.stopWithError(fmt.Errorf("linked actor terminated: %w", err))
+	}
+}
+
+// EOF
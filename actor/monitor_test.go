@@ -0,0 +1,124 @@
+// Tideland Go Together - Actor - Unit Tests
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/together/actor"
+	"tideland.dev/go/together/fuse"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestMonitor tests the notification about the termination of an Actor.
+func TestMonitor(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithFinalizer(func(err error) error {
+		return errors.New("finalized")
+	}))
+	assert.OK(err)
+
+	monitor := act.Monitor()
+	assert.OK(act.DoAsync(func() {
+		panic("bam")
+	}))
+	assert.ErrorMatch(<-monitor, "finalized")
+	_, open := <-monitor
+	assert.False(open)
+
+	// Monitoring a terminated Actor.
+	assert.ErrorMatch(<-act.Monitor(), "finalized")
+}
+
+// TestLink tests the stopping of linked Actors.
+func TestLink(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	actA, err := actor.Go()
+	assert.OK(err)
+	actB, err := actor.Go()
+	assert.OK(err)
+	actC, err := actor.Go()
+	assert.OK(err)
+
+	assert.ErrorMatch(actA.Link(actA), "actor cannot link itself")
+	assert.OK(actA.Link(actB))
+	assert.OK(actB.Link(actC))
+
+	// Normal termination doesn't stop linked Actors.
+	actC.Stop()
+	assert.NoError(<-actC.Monitor())
+	assert.OK(actB.DoSync(func() {}))
+
+	// Error stops linked Actors.
+	monitor := actB.Monitor()
+	assert.OK(actA.DoAsync(func() {
+		panic("bam")
+	}))
+	assert.ErrorMatch(<-monitor, "linked actor terminated: actor panic: bam")
+	assert.ErrorMatch(actB.DoSync(func() {}), "linked actor terminated.*")
+
+	assert.ErrorMatch(actC.Link(actB), "actor doesn't work anymore")
+}
+
+// TestLinkHandler tests the notification of linked Actors.
+func TestLinkHandler(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	notified := make(chan error, 1)
+	actA, err := actor.Go()
+	assert.OK(err)
+	actB, err := actor.Go(actor.WithLinkHandler(func(err error) {
+		notified <- err
+	}))
+	assert.OK(err)
+	defer actB.Stop()
+
+	assert.OK(actA.Link(actB))
+	assert.OK(actA.DoAsync(func() {
+		panic("bam")
+	}))
+	select {
+	case err := <-notified:
+		assert.ErrorMatch(err, "actor panic: bam")
+	case <-time.After(time.Second):
+		assert.Fail("link handler not called")
+	}
+	assert.OK(actB.DoSync(func() {}))
+}
+
+// TestStatus tests the status signaling of an Actor.
+func TestStatus(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	release := make(chan struct{})
+	act, err := actor.Go(actor.WithFinalizer(func(err error) error {
+		<-release
+		return err
+	}))
+	assert.OK(err)
+	var signaler fuse.Signaler = act
+
+	assert.Equal(signaler.Status(), fuse.Working)
+	act.Stop()
+	assert.Equal(signaler.Status(), fuse.Stopping)
+	assert.ErrorMatch(signaler.Wait(fuse.Stopped, 50*time.Millisecond), ".*timeout.*")
+	close(release)
+	assert.OK(signaler.Wait(fuse.Stopped, time.Second))
+	<-act.Done(fuse.Stopped)
+	assert.Equal(signaler.Status(), fuse.Stopped)
+}
+
+// EOF
@@ -13,6 +13,10 @@ package actor // import "tideland.dev/go/together/actor"
 
 import (
 	"context"
+	"fmt"
+	"time"
+
+	"tideland.dev/go/together/fuse"
 )
 
 //--------------------
@@ -30,30 +34,119 @@ func WithContext(ctx context.Context) Option {
 	}
 }
 
-// WithQueueCap defines the channel capacity for actions sent to an Actor.
+// WithQueueCap defines the channel capacity of each priority lane for
+// actions sent to an Actor. The high and low lanes are only created
+// with their first action.
 func WithQueueCap(c int) Option {
 	return func(act *Actor) error {
 		if c < DefaultQueueCap {
 			c = DefaultQueueCap
 		}
-		act.asyncActions = make(chan Action, c)
+		act.queueCap = c
+		return nil
+	}
+}
+
+// WithIdleTimeout lets the Actor passivate its backend goroutine and
+// release its queue after the timeout without actions. The next action
+// activates it again.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(act *Actor) error {
+		if timeout <= 0 {
+			return fmt.Errorf("invalid idle timeout: %v", timeout)
+		}
+		act.idleTimeout = timeout
+		return nil
+	}
+}
+
+// WithPassivationHooks sets functions called on the backend goroutine
+// when the Actor passivates and when it activates again. Both are
+// optional.
+func WithPassivationHooks(passivate, activate func()) Option {
+	return func(act *Actor) error {
+		act.onPassivate = passivate
+		act.onActivate = activate
+		return nil
+	}
+}
+
+// WithQueuePolicy defines how the Actor handles asynchronous
+// actions when its queue is full. Default is QueueBlock.
+func WithQueuePolicy(policy QueuePolicy) Option {
+	return func(act *Actor) error {
+		if policy < QueueBlock || policy > QueueDropOldest {
+			return fmt.Errorf("invalid queue policy: %d", policy)
+		}
+		act.queuePolicy = policy
+		return nil
+	}
+}
+
+// WithInterceptors adds interceptors wrapping each action run by
+// the Actor. The first one is the outermost.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(act *Actor) error {
+		act.interceptors = append(act.interceptors, interceptors...)
+		return nil
+	}
+}
+
+// WithReentrancy defines how the Actor handles synchronous actions
+// sent from inside its own actions. Default is ReentrantError.
+func WithReentrancy(reentrancy Reentrancy) Option {
+	return func(act *Actor) error {
+		if reentrancy < ReentrantError || reentrancy > ReentrantInline {
+			return fmt.Errorf("invalid reentrancy: %d", reentrancy)
+		}
+		act.reentrancy = reentrancy
 		return nil
 	}
 }
 
 // WithRepairer defines the panic handler of an actor.
 func WithRepairer(repairer Repairer) Option {
+	return func(act *Actor) error {
+		if repairer == nil {
+			act.repairer = nil
+			return nil
+		}
+		act.repairer = func(rctx fuse.RepairContext) fuse.Decision {
+			if err := repairer(rctx.Reason); err != nil {
+				return fuse.GiveUp(err)
+			}
+			return fuse.Restart()
+		}
+		return nil
+	}
+}
+
+// WithContextRepairer defines the panic handler of an actor
+// receiving the repair context.
+func WithContextRepairer(repairer ContextRepairer) Option {
 	return func(act *Actor) error {
 		act.repairer = repairer
 		return nil
 	}
 }
 
-// WithFinalizer sets a function for finalizing the
-// work of a Loop.
+// WithLinkHandler sets a function called when a linked Actor
+// terminates. Without a handler the Actor is stopped when a linked
+// one terminates with an error.
+func WithLinkHandler(handler LinkHandler) Option {
+	return func(act *Actor) error {
+		act.linkHandler = handler
+		return nil
+	}
+}
+
+// WithFinalizer adds a function for finalizing the work of
+// an Actor. Multiple finalizers are called in the order they
+// have been added, each one receiving the error returned by
+// the former one.
 func WithFinalizer(finalizer Finalizer) Option {
 	return func(act *Actor) error {
-		act.finalizer = finalizer
+		act.finalizers = append(act.finalizers, finalizer)
 		return nil
 	}
 }
@@ -0,0 +1,191 @@
+// Tideland Go Together - Actor
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/together/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync/atomic"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// Routing defines how a Pool selects the Actor for an action.
+type Routing int
+
+// Different routings of a Pool.
+const (
+	// RoundRobin sends the actions to the Actors in turn.
+	RoundRobin Routing = iota
+
+	// LeastLoaded sends the actions to the Actor with the
+	// shortest queue.
+	LeastLoaded
+
+	// ConsistentHash sends actions with the same key always to
+	// the same Actor, so their order is kept. Actions without
+	// key are sent in turn.
+	ConsistentHash
+)
+
+// virtualNodes is the number of points per Actor on the
+// hash ring.
+const virtualNodes = 64
+
+//--------------------
+// POOL
+//--------------------
+
+// ringPoint is one point on the hash ring.
+type ringPoint struct {
+	hash  uint32
+	index int
+}
+
+// Pool runs multiple Actors and routes the actions to them.
+type Pool struct {
+	actors  []*Actor
+	routing Routing
+	counter uint64
+	ring    []ringPoint
+}
+
+// GoPool starts a Pool of size Actors, each one with the
+// passed options.
+func GoPool(size int, routing Routing, options ...Option) (*Pool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("invalid pool size: %d", size)
+	}
+	if routing < RoundRobin || routing > ConsistentHash {
+		return nil, fmt.Errorf("invalid pool routing: %d", routing)
+	}
+	p := &Pool{
+		routing: routing,
+	}
+	for i := 0; i < size; i++ {
+		act, err := Go(options...)
+		if err != nil {
+			p.Stop()
+			return nil, err
+		}
+		p.actors = append(p.actors, act)
+		for v := 0; v < virtualNodes; v++ {
+			p.ring = append(p.ring, ringPoint{
+				hash:  hash(fmt.Sprintf("%d-%d", i, v)),
+				index: i,
+			})
+		}
+	}
+	sort.Slice(p.ring, func(i, j int) bool {
+		return p.ring[i].hash < p.ring[j].hash
+	})
+	return p, nil
+}
+
+// Size returns the number of Actors of the Pool.
+func (p *Pool) Size() int {
+	return len(p.actors)
+}
+
+// DoAsync sends the action to one of the Actors and returns
+// when it's queued.
+func (p *Pool) DoAsync(action Action) error {
+	return p.next().DoAsync(action)
+}
+
+// DoAsyncKey sends the action to the Actor responsible for the
+// key and returns when it's queued. The key is only used with
+// the routing ConsistentHash.
+func (p *Pool) DoAsyncKey(key string, action Action) error {
+	return p.nextFor(key).DoAsync(action)
+}
+
+// DoSync executes the action on one of the Actors and returns
+// when it's done or it has the default timeout.
+func (p *Pool) DoSync(action Action) error {
+	return p.next().DoSync(action)
+}
+
+// DoSyncKey executes the action on the Actor responsible for the
+// key and returns when it's done or it has the default timeout.
+// The key is only used with the routing ConsistentHash.
+func (p *Pool) DoSyncKey(key string, action Action) error {
+	return p.nextFor(key).DoSync(action)
+}
+
+// Err returns the first error of the Actors if one has one.
+func (p *Pool) Err() error {
+	for _, act := range p.actors {
+		if err := act.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop terminates all Actors of the Pool.
+func (p *Pool) Stop() {
+	for _, act := range p.actors {
+		act.Stop()
+	}
+}
+
+// next returns the Actor for an action without key.
+func (p *Pool) next() *Actor {
+	n := atomic.AddUint64(&p.counter, 1)
+	size := uint64(len(p.actors))
+	if p.routing != LeastLoaded {
+		return p.actors[n%size]
+	}
+	// Start search at a rotating offset to spread
+	// the actions over equally loaded Actors.
+	least := p.actors[n%size]
+	leastLen := least.queueLen()
+	for i := uint64(1); i < size; i++ {
+		act := p.actors[(n+i)%size]
+		if l := act.queueLen(); l < leastLen {
+			least, leastLen = act, l
+		}
+	}
+	return least
+}
+
+// nextFor returns the Actor for an action with key.
+func (p *Pool) nextFor(key string) *Actor {
+	if p.routing != ConsistentHash {
+		return p.next()
+	}
+	h := hash(key)
+	i := sort.Search(len(p.ring), func(i int) bool {
+		return p.ring[i].hash >= h
+	})
+	if i == len(p.ring) {
+		i = 0
+	}
+	return p.actors[p.ring[i].index]
+}
+
+//--------------------
+// PRIVATE HELPER
+//--------------------
+
+// hash returns the FNV-1a hash of the string.
+func hash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// EOF
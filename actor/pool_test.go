@@ -0,0 +1,131 @@
+// Tideland Go Together - Actor - Unit Tests
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/together/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestPoolRoundRobin tests the distribution of actions in turn.
+func TestPoolRoundRobin(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	p, err := actor.GoPool(4, actor.RoundRobin)
+	assert.OK(err)
+	defer p.Stop()
+	assert.Equal(p.Size(), 4)
+
+	assert.True(runsConcurrently(p, 4))
+}
+
+// TestPoolLeastLoaded tests the distribution of actions to the
+// Actors with the shortest queues.
+func TestPoolLeastLoaded(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	p, err := actor.GoPool(4, actor.LeastLoaded)
+	assert.OK(err)
+	defer p.Stop()
+
+	assert.True(runsConcurrently(p, 4))
+}
+
+// TestPoolConsistentHash tests that actions with the same key are
+// executed by the same Actor in order.
+func TestPoolConsistentHash(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	p, err := actor.GoPool(4, actor.ConsistentHash)
+	assert.OK(err)
+	defer p.Stop()
+
+	var mu sync.Mutex
+	sequences := map[string][]int{}
+	for i := 0; i < 100; i++ {
+		i := i
+		key := fmt.Sprintf("key-%d", i%10)
+		assert.OK(p.DoAsyncKey(key, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			sequences[key] = append(sequences[key], i)
+		}))
+	}
+	var wg sync.WaitGroup
+	wg.Add(10)
+	for i := 0; i < 10; i++ {
+		assert.OK(p.DoAsyncKey(fmt.Sprintf("key-%d", i), wg.Done))
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Length(sequences, 10)
+	for key, sequence := range sequences {
+		assert.Length(sequence, 10, key)
+		for j := 1; j < len(sequence); j++ {
+			assert.True(sequence[j-1] < sequence[j], key)
+		}
+	}
+}
+
+// TestPoolInvalid tests the creation of pools with invalid arguments.
+func TestPoolInvalid(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	_, err := actor.GoPool(0, actor.RoundRobin)
+	assert.ErrorMatch(err, "invalid pool size: 0")
+	_, err = actor.GoPool(1, actor.Routing(99))
+	assert.ErrorMatch(err, "invalid pool routing: 99")
+}
+
+//--------------------
+// HELPER
+//--------------------
+
+// runsConcurrently checks if n actions sent to the pool are
+// executed by different Actors at the same time.
+func runsConcurrently(p *actor.Pool, n int) bool {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	release := make(chan struct{})
+	defer close(release)
+	for i := 0; i < n; i++ {
+		if err := p.DoAsync(func() {
+			wg.Done()
+			<-release
+		}); err != nil {
+			return false
+		}
+		// Give the Actor time to dequeue.
+		time.Sleep(5 * time.Millisecond)
+	}
+	started := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(started)
+	}()
+	select {
+	case <-started:
+		return true
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
+// EOF
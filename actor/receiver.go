@@ -0,0 +1,157 @@
+// Tideland Go Together - Actor
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/together/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+//--------------------
+// FUNCTION TYPES
+//--------------------
+
+// Receive defines the signature of a message handler of a Receiver.
+// Returning an error stops the Receiver with this error.
+type Receive func(r *Receiver, msg interface{}) error
+
+//--------------------
+// RECEIVER
+//--------------------
+
+// Receiver is an Actor processing messages with its current Receive
+// function. Handlers can switch the current one with Become() and
+// defer messages with Stash(). These methods must only be called from
+// inside a handler, only Stashed() can be called from anywhere.
+type Receiver struct {
+	act       *Actor
+	behaviors []Receive
+	current   interface{}
+	handling  bool
+	stash     []interface{}
+	stashed   int32
+	unstashed []interface{}
+}
+
+// GoReceiver starts a Receiver with the initial Receive function
+// and the options of the underlying Actor.
+func GoReceiver(receive Receive, options ...Option) (*Receiver, error) {
+	if receive == nil {
+		return nil, fmt.Errorf("receiver needs receive function")
+	}
+	act, err := Go(options...)
+	if err != nil {
+		return nil, err
+	}
+	return &Receiver{
+		act:       act,
+		behaviors: []Receive{receive},
+	}, nil
+}
+
+// Send queues the message for the Receiver.
+func (r *Receiver) Send(msg interface{}) error {
+	return r.act.DoAsync(func() {
+		r.receive(msg)
+	})
+}
+
+// Become replaces the current Receive function. It is used
+// for the next message. It must only be called from inside
+// a handler.
+func (r *Receiver) Become(receive Receive) {
+	r.behaviors[len(r.behaviors)-1] = receive
+}
+
+// BecomeStacked pushes the Receive function on top of the current
+// one. Unbecome() returns to the current one. It must only be
+// called from inside a handler.
+func (r *Receiver) BecomeStacked(receive Receive) {
+	r.behaviors = append(r.behaviors, receive)
+}
+
+// Unbecome returns to the Receive function used before the last
+// BecomeStacked(). The initial one is never removed. It must
+// only be called from inside a handler.
+func (r *Receiver) Unbecome() {
+	if len(r.behaviors) > 1 {
+		r.behaviors = r.behaviors[:len(r.behaviors)-1]
+	}
+}
+
+// Stash defers the currently handled message until UnstashAll()
+// is called.
+func (r *Receiver) Stash() error {
+	if !r.handling {
+		return fmt.Errorf("receiver stash outside of handler")
+	}
+	r.stash = append(r.stash, r.current)
+	atomic.StoreInt32(&r.stashed, int32(len(r.stash)))
+	return nil
+}
+
+// UnstashAll lets the Receiver process all stashed messages in their
+// order right after the current one and before further sent messages.
+// It must only be called from inside a handler.
+func (r *Receiver) UnstashAll() {
+	r.unstashed = append(r.unstashed, r.stash...)
+	r.stash = nil
+	atomic.StoreInt32(&r.stashed, 0)
+}
+
+// Stashed returns the number of stashed messages. It can be
+// called from anywhere.
+func (r *Receiver) Stashed() int {
+	return int(atomic.LoadInt32(&r.stashed))
+}
+
+// Actor returns the underlying Actor, e.g. for monitoring
+// or linking.
+func (r *Receiver) Actor() *Actor {
+	return r.act
+}
+
+// Err returns information if the Receiver has an error.
+func (r *Receiver) Err() error {
+	return r.act.Err()
+}
+
+// Stop terminates the Receiver.
+func (r *Receiver) Stop() {
+	r.act.Stop()
+}
+
+// receive handles the message and all messages unstashed
+// while doing so.
+func (r *Receiver) receive(msg interface{}) {
+	r.handle(msg)
+	for len(r.unstashed) > 0 && r.act.ctx.Err() == nil {
+		msg, r.unstashed = r.unstashed[0], r.unstashed[1:]
+		r.handle(msg)
+	}
+}
+
+// handle lets the current Receive function handle one message.
+func (r *Receiver) handle(msg interface{}) {
+	r.current = msg
+	r.handling = true
+	defer func() {
+		r.current = nil
+		r.handling = false
+	}()
+	receive := r.behaviors[len(r.behaviors)-1]
+	if err := receive(r, msg); err != nil {
+		r.act.stopWithError(err)
+	}
+}
+
+// EOF
@@ -0,0 +1,125 @@
+// Tideland Go Together - Actor - Unit Tests
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/together/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestReceiverBecome tests switching the behavior of a Receiver
+// and stashing messages.
+func TestReceiverBecome(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	sent := make(chan string, 10)
+	var closed, open actor.Receive
+	closed = func(r *actor.Receiver, msg interface{}) error {
+		switch msg {
+		case "open":
+			r.Become(open)
+			r.UnstashAll()
+			return nil
+		default:
+			return r.Stash()
+		}
+	}
+	open = func(r *actor.Receiver, msg interface{}) error {
+		switch msg {
+		case "close":
+			r.Become(closed)
+		default:
+			sent <- msg.(string)
+		}
+		return nil
+	}
+	r, err := actor.GoReceiver(closed)
+	assert.OK(err)
+	defer r.Stop()
+
+	assert.OK(r.Send("a"))
+	assert.OK(r.Send("b"))
+	assert.Retry(func() bool {
+		return r.Stashed() == 2
+	}, 100, time.Millisecond)
+	assert.OK(r.Send("open"))
+	assert.OK(r.Send("c"))
+	assert.OK(r.Send("close"))
+	assert.OK(r.Send("d"))
+	assert.Equal(<-sent, "a")
+	assert.Equal(<-sent, "b")
+	assert.Equal(<-sent, "c")
+	assert.OK(r.Send("open"))
+	assert.Equal(<-sent, "d")
+}
+
+// TestReceiverStacked tests stacked behaviors of a Receiver.
+func TestReceiverStacked(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	handled := make(chan string, 10)
+	var upper actor.Receive
+	lower := func(r *actor.Receiver, msg interface{}) error {
+		if msg == "push" {
+			r.BecomeStacked(upper)
+			return nil
+		}
+		handled <- "lower"
+		return nil
+	}
+	upper = func(r *actor.Receiver, msg interface{}) error {
+		if msg == "pop" {
+			r.Unbecome()
+			return nil
+		}
+		handled <- "upper"
+		return nil
+	}
+	r, err := actor.GoReceiver(lower)
+	assert.OK(err)
+	defer r.Stop()
+
+	for _, msg := range []string{"x", "push", "x", "pop", "pop", "x"} {
+		assert.OK(r.Send(msg))
+	}
+	assert.Equal(<-handled, "lower")
+	assert.Equal(<-handled, "upper")
+	assert.Equal(<-handled, "lower")
+}
+
+// TestReceiverError tests stopping a Receiver when a
+// handler returns an error.
+func TestReceiverError(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	_, err := actor.GoReceiver(nil)
+	assert.ErrorMatch(err, "receiver needs receive function")
+
+	r, err := actor.GoReceiver(func(r *actor.Receiver, msg interface{}) error {
+		return errors.New("cannot handle")
+	})
+	assert.OK(err)
+	assert.ErrorMatch(r.Stash(), "receiver stash outside of handler")
+
+	monitor := r.Actor().Monitor()
+	assert.OK(r.Send("foo"))
+	assert.ErrorMatch(<-monitor, "cannot handle")
+	assert.ErrorMatch(r.Err(), "cannot handle")
+	assert.ErrorMatch(r.Send("bar"), "cannot handle")
+}
+
+// EOF
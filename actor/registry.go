@@ -0,0 +1,118 @@
+// Tideland Go Together - Actor
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/together/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// NameConflictError is returned when an Actor is registered with
+// a name already used in the Registry.
+type NameConflictError struct {
+	Name string
+}
+
+// Error implements the error interface.
+func (e *NameConflictError) Error() string {
+	return fmt.Sprintf("actor name %q already registered", e.Name)
+}
+
+//--------------------
+// REGISTRY
+//--------------------
+
+// Registry allows to find Actors by their names. Terminated Actors
+// are deregistered automatically.
+type Registry struct {
+	mu     sync.RWMutex
+	actors map[string]*Actor
+	exits  map[string]uint64
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		actors: make(map[string]*Actor),
+		exits:  make(map[string]uint64),
+	}
+}
+
+// Register adds the Actor with the name. If the name is already
+// used a *NameConflictError is returned.
+func (r *Registry) Register(name string, act *Actor) error {
+	if name == "" {
+		return fmt.Errorf("actor name must not be empty")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.actors[name]; ok {
+		return &NameConflictError{Name: name}
+	}
+	exit, ok := act.addExit(func() {
+		r.remove(name, act)
+	})
+	if !ok {
+		return fmt.Errorf("actor doesn't work anymore")
+	}
+	r.actors[name] = act
+	r.exits[name] = exit
+	return nil
+}
+
+// Lookup returns the Actor registered with the name.
+func (r *Registry) Lookup(name string) (*Actor, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	act, ok := r.actors[name]
+	return act, ok
+}
+
+// Deregister removes the Actor registered with the name.
+func (r *Registry) Deregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if act, ok := r.actors[name]; ok {
+		act.removeExit(r.exits[name])
+		delete(r.actors, name)
+		delete(r.exits, name)
+	}
+}
+
+// Names returns the sorted names of all registered Actors.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.actors))
+	for name := range r.actors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// remove deregisters the name if it still belongs to the Actor.
+func (r *Registry) remove(name string, act *Actor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.actors[name] == act {
+		delete(r.actors, name)
+		delete(r.exits, name)
+	}
+}
+
+// EOF
@@ -0,0 +1,92 @@
+// Tideland Go Together - Actor - Unit Tests
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/together/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestRegistry tests registering, looking up, and deregistering Actors.
+func TestRegistry(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	r := actor.NewRegistry()
+	actA, err := actor.Go()
+	assert.OK(err)
+	defer actA.Stop()
+	actB, err := actor.Go()
+	assert.OK(err)
+	defer actB.Stop()
+
+	assert.ErrorMatch(r.Register("", actA), "actor name must not be empty")
+	assert.OK(r.Register("a", actA))
+	assert.OK(r.Register("b", actB))
+	err = r.Register("a", actB)
+	var nce *actor.NameConflictError
+	assert.True(errors.As(err, &nce))
+	assert.Equal(nce.Name, "a")
+	assert.Equal(r.Names(), []string{"a", "b"})
+
+	act, ok := r.Lookup("a")
+	assert.True(ok)
+	assert.Equal(act, actA)
+	r.Deregister("a")
+	_, ok = r.Lookup("a")
+	assert.False(ok)
+	assert.OK(r.Register("a", actB))
+	assert.Equal(r.Names(), []string{"a", "b"})
+}
+
+// TestRegistryTermination tests the automatic deregistration
+// of terminated Actors.
+func TestRegistryTermination(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	r := actor.NewRegistry()
+	actA, err := actor.Go()
+	assert.OK(err)
+	actB, err := actor.Go()
+	assert.OK(err)
+
+	assert.OK(r.Register("a", actA))
+	assert.OK(r.Register("b", actB))
+	assert.OK(r.Register("c", actA))
+	for i := 0; i < 100; i++ {
+		assert.OK(r.Register("d", actA))
+		r.Deregister("d")
+	}
+
+	actA.Stop()
+	<-actA.Monitor()
+	assert.Equal(r.Names(), []string{"b"})
+	assert.ErrorMatch(r.Register("a", actA), "actor doesn't work anymore")
+
+	// Name used by another Actor is kept.
+	r.Deregister("b")
+	actC, err := actor.Go()
+	assert.OK(err)
+	defer actC.Stop()
+	assert.OK(r.Register("b", actC))
+	actB.Stop()
+	<-actB.Monitor()
+	act, ok := r.Lookup("b")
+	assert.True(ok)
+	assert.Equal(act, actC)
+}
+
+// EOF
@@ -0,0 +1,166 @@
+// Tideland Go Together - Actor
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/together/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sync"
+	"time"
+)
+
+//--------------------
+// HISTOGRAM
+//--------------------
+
+// histogramBounds are the upper bounds of the histogram buckets.
+var histogramBounds = []time.Duration{
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// Histogram counts durations in buckets. Counts[i] contains the
+// number of durations up to Bounds[i], the last count the ones
+// above all bounds.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+}
+
+// newHistogram creates an empty histogram.
+func newHistogram() Histogram {
+	return Histogram{
+		Bounds: histogramBounds,
+		Counts: make([]uint64, len(histogramBounds)+1),
+	}
+}
+
+// add counts the duration in its bucket.
+func (h Histogram) add(d time.Duration) {
+	for i, bound := range h.Bounds {
+		if d <= bound {
+			h.Counts[i]++
+			return
+		}
+	}
+	h.Counts[len(h.Bounds)]++
+}
+
+// copy returns an independent copy of the histogram.
+func (h Histogram) copy() Histogram {
+	counts := make([]uint64, len(h.Counts))
+	copy(counts, h.Counts)
+	return Histogram{
+		Bounds: h.Bounds,
+		Counts: counts,
+	}
+}
+
+//--------------------
+// STATS
+//--------------------
+
+// Stats contains a snapshot of the runtime statistics of an Actor.
+// QueueLen and QueueCap cover all created priority lanes.
+type Stats struct {
+	QueueLen       int
+	QueueCap       int
+	SyncProcessed  uint64
+	AsyncProcessed uint64
+	QueueDelay     Histogram
+	Execution      Histogram
+	Repaired       uint64
+	Uptime         time.Duration
+}
+
+// stats collects the runtime statistics of an Actor.
+type stats struct {
+	mu             sync.Mutex
+	started        time.Time
+	stopped        time.Time
+	syncProcessed  uint64
+	asyncProcessed uint64
+	queueDelay     Histogram
+	execution      Histogram
+	repaired       uint64
+}
+
+// newStats creates the statistics for a starting Actor.
+func newStats() *stats {
+	return &stats{
+		started:    time.Now(),
+		queueDelay: newHistogram(),
+		execution:  newHistogram(),
+	}
+}
+
+// processed records one processed action.
+func (s *stats) processed(sync bool, delay, execution time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sync {
+		s.syncProcessed++
+	} else {
+		s.asyncProcessed++
+	}
+	s.queueDelay.add(delay)
+	s.execution.add(execution)
+}
+
+// stop records the end of the Actor, so that its
+// uptime doesn't grow anymore.
+func (s *stats) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = time.Now()
+}
+
+// uptime returns the time the Actor has been running. The
+// caller must hold the mutex.
+func (s *stats) uptime() time.Duration {
+	if s.stopped.IsZero() {
+		return time.Since(s.started)
+	}
+	return s.stopped.Sub(s.started)
+}
+
+// repair records one repaired panic.
+func (s *stats) repair() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repaired++
+}
+
+//--------------------
+// ACTOR
+//--------------------
+
+// Stats returns a snapshot of the runtime statistics of the Actor.
+func (act *Actor) Stats() Stats {
+	act.stats.mu.Lock()
+	defer act.stats.mu.Unlock()
+	return Stats{
+		QueueLen:       act.queueLen(),
+		QueueCap:       act.queueCapTotal(),
+		SyncProcessed:  act.stats.syncProcessed,
+		AsyncProcessed: act.stats.asyncProcessed,
+		QueueDelay:     act.stats.queueDelay.copy(),
+		Execution:      act.stats.execution.copy(),
+		Repaired:       act.stats.repaired,
+		Uptime:         act.stats.uptime(),
+	}
+}
+
+// EOF
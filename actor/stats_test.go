@@ -0,0 +1,80 @@
+// Tideland Go Together - Actor - Unit Tests
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/together/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestStats tests the collecting of runtime statistics.
+func TestStats(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithRepairer(func(reason interface{}) error {
+		return nil
+	}))
+	assert.OK(err)
+
+	blocker := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		<-blocker
+	}))
+	for i := 0; i < 5; i++ {
+		assert.OK(act.DoAsync(func() {}))
+	}
+	assert.OK(act.DoAsync(func() {
+		panic("bam")
+	}))
+	time.Sleep(20 * time.Millisecond)
+	stats := act.Stats()
+	assert.Equal(stats.QueueLen, 6)
+	assert.Equal(stats.QueueCap, actor.DefaultQueueCap)
+
+	close(blocker)
+	for act.Stats().AsyncProcessed < 7 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		assert.OK(act.DoSync(func() {}))
+	}
+
+	stats = act.Stats()
+	assert.Equal(stats.QueueLen, 0)
+	assert.Equal(stats.SyncProcessed, uint64(3))
+	assert.Equal(stats.AsyncProcessed, uint64(7))
+	assert.Equal(stats.Repaired, uint64(1))
+	assert.True(stats.Uptime >= 20*time.Millisecond)
+	assert.Length(stats.QueueDelay.Counts, len(stats.QueueDelay.Bounds)+1)
+	var delays, executions uint64
+	for i := range stats.QueueDelay.Counts {
+		delays += stats.QueueDelay.Counts[i]
+		executions += stats.Execution.Counts[i]
+	}
+	assert.Equal(delays, uint64(10))
+	assert.Equal(executions, uint64(10))
+
+	// Uptime ends with the Actor.
+	act.Stop()
+	assert.NoError(<-act.Monitor())
+	uptime := act.Stats().Uptime
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(act.Stats().Uptime, uptime)
+}
+
+// EOF
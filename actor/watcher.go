@@ -0,0 +1,95 @@
+// Tideland Go Together - Actor
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/together/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"sync"
+)
+
+//--------------------
+// WATCHER
+//--------------------
+
+// watcher activates the passivated Actors of a parent context when
+// it is done, so that they finalize. So passivated Actors need no
+// own goroutine.
+type watcher struct {
+	actors map[*Actor]struct{}
+	stop   chan struct{}
+}
+
+// watchers contains the watcher of each parent context with
+// passivated Actors.
+var watchers = struct {
+	mu sync.Mutex
+	m  map[context.Context]*watcher
+}{
+	m: make(map[context.Context]*watcher),
+}
+
+// watch adds the passivated Actor to the watcher of its parent
+// context. A new watcher is started if needed.
+func watch(act *Actor) {
+	watchers.mu.Lock()
+	defer watchers.mu.Unlock()
+	w, ok := watchers.m[act.parent]
+	if !ok {
+		w = &watcher{
+			actors: make(map[*Actor]struct{}),
+			stop:   make(chan struct{}),
+		}
+		watchers.m[act.parent] = w
+		go w.run(act.parent)
+	}
+	w.actors[act] = struct{}{}
+}
+
+// unwatch removes the activated Actor from the watcher of its parent
+// context. The watcher ends when it has no more Actors.
+func unwatch(act *Actor) {
+	watchers.mu.Lock()
+	defer watchers.mu.Unlock()
+	w, ok := watchers.m[act.parent]
+	if !ok {
+		return
+	}
+	delete(w.actors, act)
+	if len(w.actors) == 0 {
+		delete(watchers.m, act.parent)
+		close(w.stop)
+	}
+}
+
+// run waits until the parent context is done and activates all
+// watched Actors then.
+func (w *watcher) run(parent context.Context) {
+	select {
+	case <-parent.Done():
+	case <-w.stop:
+		return
+	}
+	watchers.mu.Lock()
+	if watchers.m[parent] == w {
+		delete(watchers.m, parent)
+	}
+	actors := w.actors
+	w.actors = nil
+	watchers.mu.Unlock()
+	for act := range actors {
+		act.mu.Lock()
+		act.activate()
+		act.mu.Unlock()
+	}
+}
+
+// EOF
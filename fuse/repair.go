@@ -0,0 +1,149 @@
+// Tideland Go Together - Fuse
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package fuse // import "tideland.dev/go/together/fuse"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sync"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// keptReasons is the number of reasons kept by a RepairLog.
+const keptReasons = 100
+
+//--------------------
+// REPAIR CONTEXT
+//--------------------
+
+// RepairContext describes the situation of a component that
+// has to be repaired after a panic.
+type RepairContext struct {
+	// Reason is the recovered panic value.
+	Reason interface{}
+
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+
+	// Repairs is the number of former successful repairs.
+	Repairs int
+
+	// Reasons contains the former reasons including the current one.
+	Reasons Reasons
+
+	// SinceRestart is the time since the last start or repair.
+	SinceRestart time.Duration
+}
+
+//--------------------
+// DECISION
+//--------------------
+
+// Decision tells a component how to continue after a repair.
+type Decision struct {
+	delay time.Duration
+	err   error
+}
+
+// Restart lets the component continue immediately.
+func Restart() Decision {
+	return Decision{}
+}
+
+// RestartAfter lets the component continue after the delay.
+func RestartAfter(delay time.Duration) Decision {
+	return Decision{
+		delay: delay,
+	}
+}
+
+// GiveUp lets the component terminate with the error.
+func GiveUp(err error) Decision {
+	if err == nil {
+		err = failure.New("repair given up")
+	}
+	return Decision{
+		err: err,
+	}
+}
+
+// Delay returns the delay before the component continues.
+func (d Decision) Delay() time.Duration {
+	return d.delay
+}
+
+// Err returns the error if the component shall terminate.
+func (d Decision) Err() error {
+	return d.err
+}
+
+//--------------------
+// REPAIR LOG
+//--------------------
+
+// RepairLog collects the repair history of a component and creates
+// the RepairContext for each panic.
+type RepairLog struct {
+	mu        sync.Mutex
+	reasons   Reasons
+	repairs   int
+	restarted time.Time
+}
+
+// NewRepairLog creates a RepairLog for a just started component.
+func NewRepairLog() *RepairLog {
+	return &RepairLog{
+		restarted: time.Now(),
+	}
+}
+
+// Context logs the reason and returns the RepairContext for it.
+func (rl *RepairLog) Context(reason interface{}, stack []byte) RepairContext {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.reasons.Append(reason)
+	rl.reasons.Trim(keptReasons)
+	reasons := Reasons{
+		reasons: make([]Reason, len(rl.reasons.reasons)),
+	}
+	copy(reasons.reasons, rl.reasons.reasons)
+	return RepairContext{
+		Reason:       reason,
+		Stack:        stack,
+		Repairs:      rl.repairs,
+		Reasons:      reasons,
+		SinceRestart: time.Since(rl.restarted),
+	}
+}
+
+// Repaired counts a successful repair and restarts the
+// measuring of the time since restart.
+func (rl *RepairLog) Repaired() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.repairs++
+	rl.restarted = time.Now()
+}
+
+// Restarted notes a restart of the component without a repair,
+// e.g. by a restart policy.
+func (rl *RepairLog) Restarted() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.restarted = time.Now()
+}
+
+// EOF
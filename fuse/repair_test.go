@@ -0,0 +1,78 @@
+// Tideland Go Together - Fuse - Unit Tests
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package fuse_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/together/fuse"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestRepairLog tests the creation of repair contexts.
+func TestRepairLog(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	rl := fuse.NewRepairLog()
+
+	// Test.
+	rctx := rl.Context("one", []byte("stack"))
+	assert.Equal(rctx.Reason, "one")
+	assert.Equal(string(rctx.Stack), "stack")
+	assert.Equal(rctx.Repairs, 0)
+	assert.Equal(rctx.Reasons.Len(), 1)
+	rl.Repaired()
+	time.Sleep(10 * time.Millisecond)
+	rctx = rl.Context("two", nil)
+	assert.Equal(rctx.Repairs, 1)
+	assert.Equal(rctx.Reasons.Len(), 2)
+	assert.Equal(rctx.Reasons.Last().Reason, "two")
+	assert.True(rctx.SinceRestart >= 10*time.Millisecond)
+	rl.Restarted()
+	rctx = rl.Context("restarted", nil)
+	assert.Equal(rctx.Repairs, 1)
+	assert.True(rctx.SinceRestart < 10*time.Millisecond)
+
+	// Reasons of a context are independent.
+	rctx.Reasons.Append("three")
+	assert.Equal(rl.Context("four", nil).Reasons.Last().Reason, "four")
+	for i := 0; i < 200; i++ {
+		rctx = rl.Context(i, nil)
+	}
+	assert.Equal(rctx.Reasons.Len(), 100)
+}
+
+// TestDecision tests the repair decisions.
+func TestDecision(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	// Test.
+	d := fuse.Restart()
+	assert.Equal(d.Delay(), time.Duration(0))
+	assert.NoError(d.Err())
+	d = fuse.RestartAfter(time.Second)
+	assert.Equal(d.Delay(), time.Second)
+	assert.NoError(d.Err())
+	d = fuse.GiveUp(errors.New("ouch"))
+	assert.ErrorMatch(d.Err(), "ouch")
+	d = fuse.GiveUp(nil)
+	assert.ErrorMatch(d.Err(), ".*repair given up.*")
+}
+
+// EOF
@@ -0,0 +1,60 @@
+// Tideland Go Together - Internal - Goroutine
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+// Package goroutine provides information about goroutines for
+// the diagnostics of the other packages.
+package goroutine // import "tideland.dev/go/together/internal/goroutine"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+)
+
+//--------------------
+// GOROUTINE
+//--------------------
+
+// ID returns the ID of the calling goroutine.
+func ID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	// Stack starts with "goroutine <id> [".
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i > 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
+
+// Stack returns the stack trace of the goroutine with the ID. If
+// it doesn't exist anymore nil is returned.
+func Stack(id uint64) []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	prefix := []byte("goroutine " + strconv.FormatUint(id, 10) + " [")
+	for _, trace := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(trace, prefix) {
+			return trace
+		}
+	}
+	return nil
+}
+
+// EOF
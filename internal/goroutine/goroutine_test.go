@@ -0,0 +1,46 @@
+// Tideland Go Together - Internal - Goroutine - Unit Tests
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package goroutine_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/together/internal/goroutine"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestIDAndStack tests retrieving IDs and stacks of goroutines.
+func TestIDAndStack(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	id := goroutine.ID()
+	assert.True(id > 0)
+
+	idc := make(chan uint64)
+	release := make(chan struct{})
+	go func() {
+		idc <- goroutine.ID()
+		<-release
+	}()
+	other := <-idc
+	assert.Different(other, id)
+	assert.Contains("TestIDAndStack.func1", string(goroutine.Stack(other)))
+	assert.Contains("TestIDAndStack(", string(goroutine.Stack(id)))
+	close(release)
+	assert.Nil(goroutine.Stack(0))
+}
+
+// EOF
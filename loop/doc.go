@@ -48,6 +48,56 @@
 // a possible internal error. Also recovering of internal panics with
 // a repairer function passed as option is possible. See the code
 // examples.
+//
+// A repairer set with WithContextRepairer() receives a fuse.RepairContext
+// with the reason and stack of the panic, the number of former repairs,
+// the history of reasons, and the time since the last restart. It returns
+// a fuse.Decision to restart, restart after a delay, or give up.
+//
+// GoTicked() starts a Loop calling a function on each tick of a wait.Ticker
+// instead of a worker with its own select loop. WithOverlap() defines if
+// ticks arriving while the function is running are skipped or queued.
+//
+// WithRestartPolicy() lets a Loop restart its worker after panics, errors,
+// or always, with an exponentially growing and jittering delay and an
+// optional maximum number of restarts.
+//
+// WithWatchdog() lets a Loop expect calls of Heartbeat() with the worker
+// context within an interval. A worker missing it, e.g. stuck in a blocking
+// call, is cancelled, abandoned, and handled with ErrHung like a panic.
+// The abandoned worker may still run concurrently to a restarted one.
+//
+// Pause() and Resume() suspend a worker without stopping it, so it keeps
+// its state. The worker gets aware of it via its context.
+//
+//     for {
+//         select {
+//         case <-ctx.Done():
+//             return nil
+//         case <-loop.Paused(ctx):
+//             if err := loop.WaitResumed(ctx); err != nil {
+//                 return nil
+//             }
+//         case <-ticker.C:
+//             ...
+//         }
+//     }
+//
+// IsPaused() reports the paused state.
+//
+// A Group starts multiple Loops under one parent context. In the mode
+// StopAll the failure of one Loop stops all others, in KeepRunning they
+// continue. Wait() and Err() return all collected errors.
+//
+// A Loop implements fuse.Signaler. So callers can wait for a clean
+// shutdown with l.Wait(fuse.Stopped, timeout) or select on
+// l.Done(fuse.Stopped), which is closed after the finalizers have
+// been called.
+//
+// StopAndWait(ctx) combines Stop() with waiting for the worker, the workers
+// abandoned by the watchdog, and the finalizers. If they ignore the
+// cancellation until the context is done it returns an *AbandonedError with
+// the stack of the still running goroutine, so the blocking call can be found.
 package loop // import "tideland.dev/go/together/loop"
 
 // EOF
@@ -0,0 +1,116 @@
+// Tideland Go Together - Loop
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package loop // import "tideland.dev/go/together/loop"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"sync"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// GROUP
+//--------------------
+
+// GroupMode defines how a Group reacts on failing Loops.
+type GroupMode int
+
+// Different modes of a Group.
+const (
+	// StopAll stops all Loops of the Group when one fails.
+	StopAll GroupMode = iota
+
+	// KeepRunning lets the other Loops run when one fails.
+	KeepRunning
+)
+
+// Group runs multiple Loops under one parent context and collects
+// their errors.
+type Group struct {
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel func()
+	mode   GroupMode
+	errs   []error
+}
+
+// NewGroup creates a Group for Loops running with the context.
+func NewGroup(ctx context.Context, mode GroupMode) (*Group, error) {
+	if ctx == nil {
+		return nil, failure.New("invalid group: context is nil")
+	}
+	if mode < StopAll || mode > KeepRunning {
+		return nil, failure.New("invalid group: invalid mode %d", mode)
+	}
+	g := &Group{
+		mode: mode,
+	}
+	g.ctx, g.cancel = context.WithCancel(ctx)
+	return g, nil
+}
+
+// Go starts a Loop with the worker and options in the Group.
+// Its context is the one of the Group.
+func (g *Group) Go(worker Worker, options ...Option) (*Loop, error) {
+	options = append(append([]Option{}, options...),
+		WithContext(g.ctx),
+		WithFinalizer(func(err error) error {
+			g.ended(err)
+			return err
+		}),
+	)
+	g.wg.Add(1)
+	l, err := Go(worker, options...)
+	if err != nil {
+		g.wg.Done()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Stop terminates all Loops of the Group.
+func (g *Group) Stop() {
+	g.cancel()
+}
+
+// Wait blocks until all Loops of the Group have been finalized
+// and returns their collected errors.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	return g.Err()
+}
+
+// Err returns the collected errors of the already finalized
+// Loops. They can be retrieved with failure.All().
+func (g *Group) Err() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return failure.Collect(g.errs...)
+}
+
+// ended is called by the finalizer of each Loop.
+func (g *Group) ended(err error) {
+	defer g.wg.Done()
+	if err == nil {
+		return
+	}
+	g.mu.Lock()
+	g.errs = append(g.errs, err)
+	g.mu.Unlock()
+	if g.mode == StopAll {
+		g.cancel()
+	}
+}
+
+// EOF
@@ -0,0 +1,89 @@
+// Tideland Go Together - Loop - Unit Tests
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package loop_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/trace/failure"
+
+	"tideland.dev/go/together/fuse"
+	"tideland.dev/go/together/loop"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestGroupStopAll tests stopping all Loops of a Group when one fails.
+func TestGroupStopAll(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	g, err := loop.NewGroup(context.Background(), loop.StopAll)
+	assert.NoError(err)
+	fail := make(chan struct{})
+
+	// Test.
+	for i := 0; i < 5; i++ {
+		_, err := g.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		})
+		assert.NoError(err)
+	}
+	_, err = g.Go(func(ctx context.Context) error {
+		<-fail
+		return errors.New("failed")
+	})
+	assert.NoError(err)
+	assert.NoError(g.Err())
+	close(fail)
+	assert.ErrorMatch(g.Wait(), "failed")
+}
+
+// TestGroupKeepRunning tests collecting the errors of the Loops
+// of a Group while the others keep running.
+func TestGroupKeepRunning(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	g, err := loop.NewGroup(context.Background(), loop.KeepRunning)
+	assert.NoError(err)
+	running, err := g.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.New("stopped")
+	})
+	assert.NoError(err)
+	for _, msg := range []string{"one", "two"} {
+		msg := msg
+		l, err := g.Go(func(ctx context.Context) error {
+			return errors.New(msg)
+		})
+		assert.NoError(err)
+		<-l.Done(fuse.Stopped)
+	}
+
+	// Test.
+	assert.Length(failure.All(g.Err()), 2)
+	assert.Equal(running.Status(), fuse.Working)
+	g.Stop()
+	errs := failure.All(g.Wait())
+	assert.Length(errs, 3)
+	assert.ErrorMatch(errs[2], "stopped")
+
+	_, err = loop.NewGroup(context.Background(), loop.GroupMode(99))
+	assert.ErrorMatch(err, ".*invalid mode 99.*")
+}
+
+// EOF
@@ -13,9 +13,15 @@ package loop // import "tideland.dev/go/together/loop"
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"tideland.dev/go/together/fuse"
+	"tideland.dev/go/together/internal/goroutine"
 )
 
 //--------------------
@@ -25,6 +31,36 @@ import (
 // timeout defines the time to wait for signals.
 const timeout = 5 * time.Second
 
+//--------------------
+// ERRORS
+//--------------------
+
+// AbandonedError is returned by StopAndWait() when the worker, a
+// worker abandoned by the watchdog, or a finalizer ignored the
+// cancellation until the context was done.
+type AbandonedError struct {
+	// Goroutine is the ID of the goroutine running the worker
+	// or the finalizers.
+	Goroutine uint64
+
+	// Stack is the stack trace of the goroutine at the time of
+	// the abandonment.
+	Stack []byte
+
+	// Err is the error of the context.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *AbandonedError) Error() string {
+	return fmt.Sprintf("loop worker ignored cancellation: %v", e.Err)
+}
+
+// Unwrap returns the error of the context.
+func (e *AbandonedError) Unwrap() error {
+	return e.Err
+}
+
 //--------------------
 // FUNCTION TYPES
 //--------------------
@@ -37,6 +73,11 @@ type Worker func(ctx context.Context) error
 // by calling the worker again.
 type Repairer func(reason interface{}) error
 
+// ContextRepairer allows the loop goroutine to react on a panic
+// with the knowledge of the repair context. Its decision tells
+// if and when the worker is called again.
+type ContextRepairer func(rctx fuse.RepairContext) fuse.Decision
+
 // Finalizer is called with the final error if the backend
 // loop terminates.
 type Finalizer func(err error) error
@@ -50,14 +91,22 @@ type Finalizer func(err error) error
 // failure as well as control how to stop, restart, or recover via
 // options.
 type Loop struct {
-	mu        sync.Mutex
-	ctx       context.Context
-	cancel    func()
-	worker    Worker
-	repairer  Repairer
-	finalizer Finalizer
-	works     bool
-	err       error
+	mu         sync.Mutex
+	ctx        context.Context
+	cancel     func()
+	worker     Worker
+	repairer   ContextRepairer
+	repairLog  *fuse.RepairLog
+	finalizers []Finalizer
+	restarter  *restarter
+	watchdog   time.Duration
+	pauser     *pauser
+	overlap    Overlap
+	signal     *fuse.Signal
+	goroutine  uint64
+	abandoned  []abandonedWorker
+	works      bool
+	err        error
 }
 
 // Go starts a loop running the given worker with the
@@ -65,9 +114,13 @@ type Loop struct {
 func Go(worker Worker, options ...Option) (*Loop, error) {
 	// Init with default values.
 	l := &Loop{
-		worker: worker,
-		works:  true,
+		worker:    worker,
+		signal:    fuse.NewSignal(),
+		pauser:    newPauser(),
+		repairLog: fuse.NewRepairLog(),
+		works:     true,
 	}
+	l.signal.Notify(fuse.Starting)
 	for _, option := range options {
 		if err := option(l); err != nil {
 			return nil, err
@@ -103,58 +156,161 @@ func (l *Loop) Err() error {
 func (l *Loop) Stop() {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	if !l.works {
-		// Already stopped.
-		return
-	}
+	// Both are idempotent. Always cancelling also covers the
+	// moment between an ended worker and its restart.
+	l.signal.Notify(fuse.Stopping)
 	l.cancel()
 }
 
+// StopAndWait terminates the Loop backend and waits until the
+// worker, the workers abandoned by the watchdog, and the finalizers
+// returned. In this case the final error of the Loop is returned.
+// If the context is done before, the Loop is abandoned and an
+// *AbandonedError containing the stack of the still running
+// goroutine is returned.
+func (l *Loop) StopAndWait(ctx context.Context) error {
+	l.Stop()
+	select {
+	case <-l.signal.Done(fuse.Stopped):
+	case <-ctx.Done():
+		return newAbandonedError(atomic.LoadUint64(&l.goroutine), ctx.Err())
+	}
+	l.mu.Lock()
+	abandoned := l.abandoned
+	l.mu.Unlock()
+	for _, aw := range abandoned {
+		select {
+		case <-aw.finished:
+		case <-ctx.Done():
+			return newAbandonedError(aw.id, ctx.Err())
+		}
+	}
+	return l.Err()
+}
+
+// Status implements fuse.Signaler. The Loop is Working as long as
+// its worker runs, Stopping when it has been told to stop, and
+// Stopped after the finalizers have been called.
+func (l *Loop) Status() fuse.Status {
+	return l.signal.Status()
+}
+
+// Done implements fuse.Signaler. Done(fuse.Stopped) returns a channel
+// closed after the finalization of the Loop.
+func (l *Loop) Done(status fuse.Status) <-chan struct{} {
+	return l.signal.Done(status)
+}
+
+// Wait implements fuse.Signaler.
+func (l *Loop) Wait(status fuse.Status, timeout time.Duration) error {
+	return l.signal.Wait(status, timeout)
+}
+
 // backend runs the loop worker as goroutine as long as
 // the it isn't terminated or recovery returned false.
 func (l *Loop) backend(started chan struct{}) {
 	defer l.finalize()
+	l.signal.Notify(fuse.Working)
 	close(started)
-	for l.works {
-		l.work()
+	for {
+		began := time.Now()
+		panicked := l.work()
+		if !l.restart(panicked, time.Since(began)) {
+			return
+		}
+		l.repairLog.Restarted()
+	}
+}
+
+// work runs the worker and handles possible panics. It
+// returns true if the worker panicked or hung.
+func (l *Loop) work() bool {
+	ctx, cancel := context.WithCancel(l.ctx)
+	defer cancel()
+	ctx = context.WithValue(ctx, pauserKey{}, l.pauser)
+	var reason interface{}
+	var stack []byte
+	var err error
+	if l.watchdog > 0 {
+		reason, stack, err = l.watch(ctx, cancel)
+	} else {
+		reason, stack, err = l.call(ctx)
+	}
+	switch {
+	case reason != nil && l.repairer != nil:
+		// Try to repair.
+		decision := l.repairer(l.repairLog.Context(reason, stack))
+		l.mu.Lock()
+		l.err = decision.Err()
+		l.works = l.err == nil
+		l.mu.Unlock()
+		if l.err == nil {
+			l.repairLog.Repaired()
+			if !l.sleep(decision.Delay()) {
+				l.mu.Lock()
+				l.works = false
+				l.mu.Unlock()
+			}
+		}
+	case reason != nil && l.repairer == nil:
+		// Accept panic or hang.
+		if herr, ok := reason.(error); ok && errors.Is(herr, ErrHung) {
+			err = herr
+		} else {
+			err = fmt.Errorf("loop panic: %v", reason)
+		}
+		l.mu.Lock()
+		l.err = err
+		l.works = false
+		l.mu.Unlock()
+	default:
+		l.mu.Lock()
+		l.err = err
+		l.works = false
+		l.mu.Unlock()
 	}
+	return reason != nil
 }
 
-// work wraps the worker and handles possible panics.
-func (l *Loop) work() {
+// call runs the worker and recovers a possible panic
+// together with its stack.
+func (l *Loop) call(ctx context.Context) (reason interface{}, stack []byte, err error) {
+	atomic.StoreUint64(&l.goroutine, goroutine.ID())
 	defer func() {
-		// Check and handle panics!
-		reason := recover()
-		switch {
-		case reason != nil && l.repairer != nil:
-			// Try to repair.
-			err := l.repairer(reason)
-			l.mu.Lock()
-			l.err = err
-			l.works = l.err == nil
-			l.mu.Unlock()
-		case reason != nil && l.repairer == nil:
-			// Accept panic.
-			l.mu.Lock()
-			l.err = fmt.Errorf("loop panic: %v", reason)
-			l.works = false
-			l.mu.Unlock()
+		// Check for panics!
+		if r := recover(); r != nil {
+			reason = r
+			stack = debug.Stack()
 		}
 	}()
-	// Work without panic.
-	err := l.worker(l.ctx)
-	l.mu.Lock()
-	l.err = err
-	l.works = false
-	l.mu.Unlock()
+	return nil, nil, l.worker(ctx)
 }
 
 // finalize takes care for a clean loop finalization.
 func (l *Loop) finalize() {
+	atomic.StoreUint64(&l.goroutine, goroutine.ID())
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	if l.finalizer != nil {
-		l.err = l.finalizer(l.err)
+	l.works = false
+	l.signal.Notify(fuse.Stopping)
+	l.cancel()
+	for _, finalizer := range l.finalizers {
+		l.err = finalizer(l.err)
+	}
+	l.signal.Notify(fuse.Stopped)
+}
+
+//--------------------
+// PRIVATE HELPER
+//--------------------
+
+// newAbandonedError creates the error for the goroutine
+// with the given ID.
+func newAbandonedError(id uint64, err error) *AbandonedError {
+	return &AbandonedError{
+		Goroutine: id,
+		Stack:     goroutine.Stack(id),
+		Err:       err,
 	}
 }
 
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/together/fuse"
 	"tideland.dev/go/together/loop"
 )
 
@@ -347,6 +348,152 @@ func TestRecoverPanicsOK(t *testing.T) {
 	assert.ErrorMatch(l.Err(), "too many panics: bam")
 }
 
+// TestStatus tests the status signaling of a loop.
+func TestStatus(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	release := make(chan struct{})
+	worker := func(ctx context.Context) error {
+		<-release
+		<-ctx.Done()
+		return errors.New("done")
+	}
+	finalizer := func(err error) error {
+		return fmt.Errorf("finalized: %v", err)
+	}
+	l, err := loop.Go(worker, loop.WithFinalizer(finalizer))
+	assert.NoError(err)
+	var signaler fuse.Signaler = l
+
+	// Test.
+	assert.Equal(signaler.Status(), fuse.Working)
+	l.Stop()
+	assert.Equal(signaler.Status(), fuse.Stopping)
+	assert.ErrorMatch(signaler.Wait(fuse.Stopped, 50*time.Millisecond), ".*timeout.*")
+	close(release)
+	<-signaler.Done(fuse.Stopped)
+	assert.Equal(signaler.Status(), fuse.Stopped)
+	assert.ErrorMatch(l.Err(), "finalized: done")
+}
+
+// TestContextRepairer tests the repair context and the decisions
+// of a context repairer.
+func TestContextRepairer(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	worker := func(ctx context.Context) error {
+		panic("bam")
+	}
+	var contexts []fuse.RepairContext
+	repairer := func(rctx fuse.RepairContext) fuse.Decision {
+		contexts = append(contexts, rctx)
+		if rctx.Repairs == 2 {
+			return fuse.GiveUp(errors.New("too many panics"))
+		}
+		return fuse.RestartAfter(20 * time.Millisecond)
+	}
+	began := time.Now()
+	l, err := loop.Go(worker, loop.WithContextRepairer(repairer))
+	assert.NoError(err)
+
+	// Test.
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+	assert.ErrorMatch(l.Err(), "too many panics")
+	assert.Length(contexts, 3)
+	for i, rctx := range contexts {
+		assert.Equal(rctx.Reason, "bam")
+		assert.Equal(rctx.Repairs, i)
+		assert.Equal(rctx.Reasons.Len(), i+1)
+		assert.Contains("loop_test.go", string(rctx.Stack))
+	}
+	// Delays are waited, but restarts are after them.
+	assert.True(time.Since(began) >= 40*time.Millisecond)
+	assert.True(contexts[2].SinceRestart < 20*time.Millisecond)
+}
+
+// TestContextRepairerWithRestartPolicy tests the time since the last
+// restart when the restarts are done by a restart policy.
+func TestContextRepairerWithRestartPolicy(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	runs := 0
+	worker := func(ctx context.Context) error {
+		runs++
+		if runs < 3 {
+			return errors.New("backend failed")
+		}
+		panic("bam")
+	}
+	var rctx fuse.RepairContext
+	repairer := func(c fuse.RepairContext) fuse.Decision {
+		rctx = c
+		return fuse.GiveUp(errors.New("given up"))
+	}
+	l, err := loop.Go(
+		worker,
+		loop.WithContextRepairer(repairer),
+		loop.WithRestartPolicy(loop.RestartPolicy{
+			Condition: loop.RestartOnError,
+			Backoff:   20 * time.Millisecond,
+		}),
+	)
+	assert.NoError(err)
+
+	// Test.
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+	assert.ErrorMatch(l.Err(), "given up")
+	assert.Equal(runs, 3)
+	assert.Equal(rctx.Repairs, 0)
+	assert.True(rctx.SinceRestart < 20*time.Millisecond)
+}
+
+// TestStopAndWait tests stopping a loop and waiting for its end.
+func TestStopAndWait(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	worker := func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.New("done")
+	}
+	l, err := loop.Go(worker)
+	assert.NoError(err)
+
+	// Test.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.ErrorMatch(l.StopAndWait(ctx), "done")
+	assert.Equal(l.Status(), fuse.Stopped)
+}
+
+// TestStopAndWaitAbandoned tests the report of a worker ignoring
+// the cancellation.
+func TestStopAndWaitAbandoned(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	release := make(chan struct{})
+	worker := func(ctx context.Context) error {
+		<-release
+		return nil
+	}
+	l, err := loop.Go(worker)
+	assert.NoError(err)
+
+	// Test.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = l.StopAndWait(ctx)
+	assert.ErrorMatch(err, "loop worker ignored cancellation: .*deadline.*")
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	var aerr *loop.AbandonedError
+	assert.True(errors.As(err, &aerr))
+	assert.True(aerr.Goroutine > 0)
+	assert.Contains("TestStopAndWaitAbandoned", string(aerr.Stack))
+	assert.Equal(l.Status(), fuse.Stopping)
+
+	close(release)
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+}
+
 //--------------------
 // EXAMPLES
 //--------------------
@@ -13,8 +13,11 @@ package loop // import "tideland.dev/go/together/loop"
 
 import (
 	"context"
+	"time"
 
 	"tideland.dev/go/trace/failure"
+
+	"tideland.dev/go/together/fuse"
 )
 
 //--------------------
@@ -37,6 +40,23 @@ func WithContext(ctx context.Context) Option {
 
 // WithRepairer defines the panic handler of a loop.
 func WithRepairer(repairer Repairer) Option {
+	return func(l *Loop) error {
+		if repairer == nil {
+			return failure.New("invalid loop option: repairer is nil")
+		}
+		l.repairer = func(rctx fuse.RepairContext) fuse.Decision {
+			if err := repairer(rctx.Reason); err != nil {
+				return fuse.GiveUp(err)
+			}
+			return fuse.Restart()
+		}
+		return nil
+	}
+}
+
+// WithContextRepairer defines the panic handler of a loop
+// receiving the repair context.
+func WithContextRepairer(repairer ContextRepairer) Option {
 	return func(l *Loop) error {
 		if repairer == nil {
 			return failure.New("invalid loop option: repairer is nil")
@@ -46,14 +66,65 @@ func WithRepairer(repairer Repairer) Option {
 	}
 }
 
-// WithFinalizer sets a function for finalizing the
-// work of a Loop.
+// WithRestartPolicy lets the Loop restart its worker after panics,
+// errors, or always, following the policy.
+func WithRestartPolicy(policy RestartPolicy) Option {
+	return func(l *Loop) error {
+		r, err := newRestarter(policy)
+		if err != nil {
+			return err
+		}
+		l.restarter = r
+		return nil
+	}
+}
+
+// WithWatchdog lets the Loop watch the heartbeat of its worker. If
+// it doesn't call Heartbeat() with its context within the interval
+// the worker is treated as hung. Its context is cancelled and it is
+// abandoned. ErrHung is passed like a panic to the Repairer or the
+// restart policy, otherwise the Loop ends with it. While the Loop is
+// paused the heartbeat isn't expected.
+//
+// The abandoned worker goroutine isn't waited for, it runs until it
+// returns by itself. So after a repair or restart the old and the
+// new instance of the worker may run concurrently. Workers accessing
+// shared state have to protect it and should check their cancelled
+// context before each change. StopAndWait() waits for abandoned
+// workers too.
+func WithWatchdog(interval time.Duration) Option {
+	return func(l *Loop) error {
+		if interval <= 0 {
+			return failure.New("invalid loop option: watchdog interval %v", interval)
+		}
+		l.watchdog = interval
+		return nil
+	}
+}
+
+// WithOverlap defines how a Loop started with GoTicked() handles
+// ticks arriving while the function is still running. Default is
+// OverlapSkip.
+func WithOverlap(overlap Overlap) Option {
+	return func(l *Loop) error {
+		if overlap < OverlapSkip || overlap > OverlapQueue {
+			return failure.New("invalid loop option: overlap %d", overlap)
+		}
+		l.overlap = overlap
+		return nil
+	}
+}
+
+// WithFinalizer adds a function for finalizing the work of
+// a Loop. Multiple finalizers are called in the order they
+// have been added, each one receiving the error returned by
+// the former one.
 func WithFinalizer(finalizer Finalizer) Option {
 	return func(l *Loop) error {
 		if finalizer == nil {
 			return failure.New("invalid loop option: finalizer is nil")
 		}
-		l.finalizer = finalizer
+		l.finalizers = append(l.finalizers, finalizer)
 		return nil
 	}
 }
@@ -0,0 +1,135 @@
+// Tideland Go Together - Loop
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package loop // import "tideland.dev/go/together/loop"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"sync"
+)
+
+//--------------------
+// PAUSER
+//--------------------
+
+// pauserKey is the context key for the pauser.
+type pauserKey struct{}
+
+// pauser manages the paused state of a Loop.
+type pauser struct {
+	mu       sync.Mutex
+	paused   bool
+	pausedc  chan struct{}
+	resumedc chan struct{}
+}
+
+// newPauser creates a pauser in resumed state.
+func newPauser() *pauser {
+	p := &pauser{
+		pausedc:  make(chan struct{}),
+		resumedc: make(chan struct{}),
+	}
+	close(p.resumedc)
+	return p
+}
+
+// pause sets the paused state.
+func (p *pauser) pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused {
+		return
+	}
+	p.paused = true
+	p.resumedc = make(chan struct{})
+	close(p.pausedc)
+}
+
+// resume resets the paused state.
+func (p *pauser) resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused {
+		return
+	}
+	p.paused = false
+	p.pausedc = make(chan struct{})
+	close(p.resumedc)
+}
+
+// isPaused returns true if paused.
+func (p *pauser) isPaused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// channels returns the current paused and resumed channels.
+func (p *pauser) channels() (<-chan struct{}, <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pausedc, p.resumedc
+}
+
+//--------------------
+// WORKER HELPERS
+//--------------------
+
+// Paused returns a channel closed when the Loop running the worker
+// with this context is paused. Workers select on it and then call
+// WaitResumed().
+func Paused(ctx context.Context) <-chan struct{} {
+	p, ok := ctx.Value(pauserKey{}).(*pauser)
+	if !ok {
+		return nil
+	}
+	pausedc, _ := p.channels()
+	return pausedc
+}
+
+// WaitResumed blocks as long as the Loop running the worker with
+// this context is paused. It returns the error of the context if
+// it is done before.
+func WaitResumed(ctx context.Context) error {
+	p, ok := ctx.Value(pauserKey{}).(*pauser)
+	if !ok {
+		return ctx.Err()
+	}
+	_, resumedc := p.channels()
+	select {
+	case <-resumedc:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//--------------------
+// LOOP
+//--------------------
+
+// Pause tells the worker to pause. It is a signal for the worker
+// received via Paused(), the Loop itself keeps running.
+func (l *Loop) Pause() {
+	l.pauser.pause()
+}
+
+// Resume lets a paused worker continue.
+func (l *Loop) Resume() {
+	l.pauser.resume()
+}
+
+// IsPaused returns true if the Loop is paused.
+func (l *Loop) IsPaused() bool {
+	return l.pauser.isPaused()
+}
+
+// EOF
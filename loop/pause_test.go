@@ -0,0 +1,83 @@
+// Tideland Go Together - Loop - Unit Tests
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package loop_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/together/fuse"
+	"tideland.dev/go/together/loop"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestPauseResume tests pausing and resuming a worker.
+func TestPauseResume(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	states := make(chan string)
+	worker := func(ctx context.Context) error {
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-loop.Paused(ctx):
+				states <- "paused"
+				if err := loop.WaitResumed(ctx); err != nil {
+					return nil
+				}
+				states <- "resumed"
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}
+	l, err := loop.Go(worker)
+	assert.NoError(err)
+
+	// Test.
+	assert.False(l.IsPaused())
+	l.Pause()
+	assert.True(l.IsPaused())
+	assert.Equal(<-states, "paused")
+	l.Pause()
+	l.Resume()
+	assert.False(l.IsPaused())
+	assert.Equal(<-states, "resumed")
+	l.Pause()
+	assert.Equal(<-states, "paused")
+
+	// Stop while paused.
+	l.Stop()
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+	assert.NoError(l.Err())
+}
+
+// TestWaitResumedWithoutLoop tests the helpers with a
+// context not passed by a Loop.
+func TestWaitResumedWithoutLoop(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Test.
+	assert.Nil(loop.Paused(ctx))
+	assert.NoError(loop.WaitResumed(ctx))
+	cancel()
+	assert.ErrorMatch(loop.WaitResumed(ctx), "context canceled")
+}
+
+// EOF
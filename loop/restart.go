@@ -0,0 +1,206 @@
+// Tideland Go Together - Loop
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package loop // import "tideland.dev/go/together/loop"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"math/rand"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+
+	"tideland.dev/go/together/wait"
+)
+
+//--------------------
+// RESTART POLICY
+//--------------------
+
+// Defaults of the restart policy.
+const (
+	defaultMaxBackoff = time.Minute
+	resetFactor       = 10
+)
+
+// RestartCondition defines when a Loop restarts its worker.
+type RestartCondition int
+
+// Different conditions for restarting a worker.
+const (
+	// RestartOnPanic restarts the worker after a panic. A set
+	// Repairer is asked before.
+	RestartOnPanic RestartCondition = iota
+
+	// RestartOnError restarts the worker when it returns an error.
+	RestartOnError
+
+	// RestartAlways restarts the worker after a panic and whenever
+	// it returns, as long as the Loop isn't stopped.
+	RestartAlways
+)
+
+// RestartPolicy defines when and how often a Loop restarts its worker
+// and how long it waits before.
+type RestartPolicy struct {
+	// Condition defines when to restart.
+	Condition RestartCondition
+
+	// MaxRestarts is the maximum number of restarts. Zero means
+	// no limit.
+	MaxRestarts int
+
+	// Backoff is the delay before the first restart. It's doubled
+	// for each following one.
+	Backoff time.Duration
+
+	// MaxBackoff limits the delay between restarts. Zero means one
+	// minute, but at least Backoff.
+	MaxBackoff time.Duration
+
+	// ResetAfter is the run time after which a worker counts as
+	// healthy, so that its next restart starts with Backoff again.
+	// Zero means ten times MaxBackoff.
+	ResetAfter time.Duration
+
+	// Jitter adds up to Jitter * delay randomly to each delay.
+	Jitter float64
+}
+
+// restarter implements the restart policy for a Loop.
+type restarter struct {
+	policy   RestartPolicy
+	restarts int
+	backoff  time.Duration
+}
+
+// newRestarter validates the policy and creates a restarter.
+func newRestarter(policy RestartPolicy) (*restarter, error) {
+	if policy.Condition < RestartOnPanic || policy.Condition > RestartAlways {
+		return nil, failure.New("invalid loop option: invalid restart condition %d", policy.Condition)
+	}
+	if policy.MaxRestarts < 0 || policy.Backoff < 0 || policy.MaxBackoff < 0 ||
+		policy.ResetAfter < 0 || policy.Jitter < 0 {
+		return nil, failure.New("invalid loop option: negative restart policy value")
+	}
+	if policy.MaxBackoff == 0 {
+		policy.MaxBackoff = defaultMaxBackoff
+	}
+	if policy.MaxBackoff < policy.Backoff {
+		policy.MaxBackoff = policy.Backoff
+	}
+	if policy.ResetAfter == 0 {
+		policy.ResetAfter = resetFactor * policy.MaxBackoff
+	}
+	return &restarter{
+		policy:  policy,
+		backoff: policy.Backoff,
+	}, nil
+}
+
+// wanted checks if the policy wants a restart after the worker
+// panicked or returned the error.
+func (r *restarter) wanted(panicked bool, err error) bool {
+	switch {
+	case r.policy.Condition == RestartAlways:
+		return true
+	case panicked:
+		return r.policy.Condition == RestartOnPanic
+	default:
+		return r.policy.Condition == RestartOnError && err != nil
+	}
+}
+
+// delay counts the restart and returns the delay before it. The
+// bool is false if the maximum number of restarts is reached.
+func (r *restarter) delay(ran time.Duration) (time.Duration, bool) {
+	r.restarts++
+	if r.policy.MaxRestarts > 0 && r.restarts > r.policy.MaxRestarts {
+		return 0, false
+	}
+	if ran >= r.policy.ResetAfter {
+		// Worker has been healthy, so start again.
+		r.backoff = r.policy.Backoff
+	}
+	d := r.backoff
+	r.backoff *= 2
+	if r.backoff > r.policy.MaxBackoff {
+		r.backoff = r.policy.MaxBackoff
+	}
+	if r.policy.Jitter > 0 {
+		d += time.Duration(rand.Float64() * r.policy.Jitter * float64(d))
+	}
+	return d, true
+}
+
+//--------------------
+// LOOP
+//--------------------
+
+// restart checks if the worker shall be restarted after it panicked
+// or ended. It waits for the backoff delay before returning true.
+func (l *Loop) restart(panicked bool, ran time.Duration) bool {
+	l.mu.Lock()
+	works, err := l.works, l.err
+	l.mu.Unlock()
+	if l.restarter == nil {
+		// Only restart repaired panics.
+		return works
+	}
+	if l.ctx.Err() != nil {
+		return false
+	}
+	repaired := panicked && l.repairer != nil
+	if repaired && !works {
+		// Repairer refused.
+		return false
+	}
+	if !repaired && !l.restarter.wanted(panicked, err) {
+		return false
+	}
+	d, ok := l.restarter.delay(ran)
+	if !ok {
+		l.mu.Lock()
+		if l.err == nil {
+			l.err = failure.New("loop exceeded %d restarts", l.restarter.policy.MaxRestarts)
+		} else {
+			l.err = failure.Annotate(l.err, "loop exceeded %d restarts", l.restarter.policy.MaxRestarts)
+		}
+		l.mu.Unlock()
+		return false
+	}
+	// Keep the Loop stoppable while waiting.
+	l.mu.Lock()
+	l.works = true
+	l.mu.Unlock()
+	if !l.sleep(d) {
+		return false
+	}
+	// Restarted worker starts without error.
+	l.mu.Lock()
+	l.err = nil
+	l.mu.Unlock()
+	return true
+}
+
+// sleep waits for the duration. It returns false if the
+// Loop has been stopped.
+func (l *Loop) sleep(d time.Duration) bool {
+	if d > 0 {
+		// Wait for the end of the ticker, not for its tick. The
+		// ticker drops ticks nobody is waiting for, but it only
+		// ends after the interval or when the Loop is stopped.
+		for range wait.MakeMaxIntervalsTicker(d, 1)(l.ctx) {
+		}
+	}
+	return l.ctx.Err() == nil
+}
+
+// EOF
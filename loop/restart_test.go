@@ -0,0 +1,206 @@
+// Tideland Go Together - Loop - Unit Tests
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package loop_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/together/fuse"
+	"tideland.dev/go/together/loop"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestRestartOnError tests restarting a worker returning errors
+// with backoff until the maximum number of restarts.
+func TestRestartOnError(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	var runs int32
+	worker := func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return errors.New("backend failed")
+	}
+	began := time.Now()
+	l, err := loop.Go(worker, loop.WithRestartPolicy(loop.RestartPolicy{
+		Condition:   loop.RestartOnError,
+		MaxRestarts: 3,
+		Backoff:     10 * time.Millisecond,
+		MaxBackoff:  time.Second,
+		Jitter:      0.5,
+	}))
+	assert.NoError(err)
+
+	// Test.
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+	assert.True(time.Since(began) >= 70*time.Millisecond)
+	assert.Equal(atomic.LoadInt32(&runs), int32(4))
+	assert.ErrorMatch(l.Err(), ".*loop exceeded 3 restarts.*backend failed.*")
+}
+
+// TestRestartOnPanic tests restarting a panicking worker and
+// the ending of a worker returning an error.
+func TestRestartOnPanic(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	var runs int32
+	worker := func(ctx context.Context) error {
+		if atomic.AddInt32(&runs, 1) < 3 {
+			panic("bam")
+		}
+		return errors.New("done")
+	}
+	l, err := loop.Go(worker, loop.WithRestartPolicy(loop.RestartPolicy{
+		Condition: loop.RestartOnPanic,
+	}))
+	assert.NoError(err)
+
+	// Test.
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+	assert.Equal(atomic.LoadInt32(&runs), int32(3))
+	assert.ErrorMatch(l.Err(), "done")
+}
+
+// TestRestartResetsError tests that a restarted worker
+// runs without the error of the former one.
+func TestRestartResetsError(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	running := make(chan struct{})
+	var runs int32
+	worker := func(ctx context.Context) error {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			return errors.New("backend failed")
+		}
+		close(running)
+		<-ctx.Done()
+		return nil
+	}
+	l, err := loop.Go(worker, loop.WithRestartPolicy(loop.RestartPolicy{
+		Condition: loop.RestartOnError,
+		Backoff:   10 * time.Millisecond,
+	}))
+	assert.NoError(err)
+
+	// Test.
+	<-running
+	assert.NoError(l.Err())
+	l.Stop()
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+	assert.NoError(l.Err())
+}
+
+// TestRestartDefaultMaxBackoff tests the exponential growth of the
+// backoff without a set MaxBackoff.
+func TestRestartDefaultMaxBackoff(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	worker := func(ctx context.Context) error {
+		return errors.New("backend failed")
+	}
+	began := time.Now()
+	l, err := loop.Go(worker, loop.WithRestartPolicy(loop.RestartPolicy{
+		Condition:   loop.RestartOnError,
+		MaxRestarts: 3,
+		Backoff:     10 * time.Millisecond,
+	}))
+	assert.NoError(err)
+
+	// Test.
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+	assert.True(time.Since(began) >= 70*time.Millisecond)
+}
+
+// TestRestartResetAfter tests that only a worker running at least
+// ResetAfter resets the backoff.
+func TestRestartResetAfter(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	var mu sync.Mutex
+	var starts, ends []time.Time
+	worker := func(ctx context.Context) error {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		run := len(starts)
+		mu.Unlock()
+		if run == 3 {
+			time.Sleep(60 * time.Millisecond)
+		}
+		mu.Lock()
+		ends = append(ends, time.Now())
+		mu.Unlock()
+		return errors.New("backend failed")
+	}
+	l, err := loop.Go(worker, loop.WithRestartPolicy(loop.RestartPolicy{
+		Condition:   loop.RestartOnError,
+		MaxRestarts: 3,
+		Backoff:     20 * time.Millisecond,
+		MaxBackoff:  time.Second,
+		ResetAfter:  40 * time.Millisecond,
+	}))
+	assert.NoError(err)
+
+	// Test.
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Length(starts, 4)
+	assert.True(starts[2].Sub(ends[1]) >= 40*time.Millisecond)
+	assert.True(starts[3].Sub(ends[2]) >= 20*time.Millisecond)
+	assert.True(starts[3].Sub(ends[2]) < 80*time.Millisecond)
+
+	_, err = loop.Go(worker, loop.WithRestartPolicy(loop.RestartPolicy{
+		Condition:  loop.RestartOnError,
+		ResetAfter: -time.Second,
+	}))
+	assert.ErrorMatch(err, ".*negative restart policy value.*")
+}
+
+// TestRestartAlways tests restarting a returning worker and
+// stopping the Loop while waiting for the restart.
+func TestRestartAlways(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	ran := make(chan struct{}, 10)
+	worker := func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	}
+	l, err := loop.Go(worker, loop.WithRestartPolicy(loop.RestartPolicy{
+		Condition:  loop.RestartAlways,
+		Backoff:    time.Millisecond,
+		MaxBackoff: time.Minute,
+	}))
+	assert.NoError(err)
+
+	// Test.
+	<-ran
+	<-ran
+	l.Stop()
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+	assert.NoError(l.Err())
+
+	_, err = loop.Go(worker, loop.WithRestartPolicy(loop.RestartPolicy{
+		Condition: loop.RestartCondition(99),
+	}))
+	assert.ErrorMatch(err, ".*invalid restart condition 99.*")
+}
+
+// EOF
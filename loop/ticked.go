@@ -0,0 +1,170 @@
+// Tideland Go Together - Loop
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package loop // import "tideland.dev/go/together/loop"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"tideland.dev/go/together/wait"
+)
+
+//--------------------
+// OVERLAP
+//--------------------
+
+// Overlap defines how a ticked Loop handles ticks arriving while
+// the function of a former tick is still running.
+type Overlap int
+
+// Different handlings of overlapping ticks.
+const (
+	// OverlapSkip drops the ticks.
+	OverlapSkip Overlap = iota
+
+	// OverlapQueue counts the ticks and runs the function once
+	// for each of them afterwards.
+	OverlapQueue
+)
+
+//--------------------
+// TICKED
+//--------------------
+
+// GoTicked starts a Loop calling fn on each tick of the ticker. The
+// Loop ends when the ticker ends or fn returns an error. Panics are
+// handled like in any Loop. Ticks arriving while the Loop is paused
+// are skipped. With a watchdog the Loop sends the heartbeats while
+// waiting for ticks, fn has to send them itself only if it runs
+// longer than the watchdog interval.
+func GoTicked(ticker wait.Ticker, fn Worker, options ...Option) (*Loop, error) {
+	t := &ticked{
+		ticker: ticker,
+		fn:     fn,
+	}
+	options = append(append([]Option{}, options...), func(l *Loop) error {
+		t.overlap = l.overlap
+		t.watchdog = l.watchdog
+		return nil
+	})
+	return Go(t.work, options...)
+}
+
+// ticked runs a function on the ticks of a ticker.
+type ticked struct {
+	ticker   wait.Ticker
+	fn       Worker
+	overlap  Overlap
+	watchdog time.Duration
+}
+
+// work is the Worker of the Loop.
+func (t *ticked) work(ctx context.Context) error {
+	tickc := t.ticker(ctx)
+	if t.overlap == OverlapQueue {
+		tickc = t.queue(ctx, tickc)
+	}
+	var beatc <-chan time.Time
+	if t.watchdog > 0 {
+		beater := time.NewTicker(t.watchdog / 2)
+		defer beater.Stop()
+		beatc = beater.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-beatc:
+			Heartbeat(ctx)
+		case <-Paused(ctx):
+			if err := WaitResumed(ctx); err != nil {
+				return nil
+			}
+		case _, ok := <-tickc:
+			if !ok {
+				return nil
+			}
+			Heartbeat(ctx)
+			if isPaused(ctx) {
+				continue
+			}
+			if err := t.fn(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// queue receives all ticks and delivers them one by one on the
+// returned channel, so that no tick is lost while fn is running.
+func (t *ticked) queue(ctx context.Context, tickc <-chan struct{}) <-chan struct{} {
+	var pending int64
+	queuedc := make(chan struct{})
+	signalc := make(chan struct{}, 1)
+	ended := make(chan struct{})
+	// Count incoming ticks.
+	go func() {
+		defer close(ended)
+		for range tickc {
+			if isPaused(ctx) {
+				continue
+			}
+			atomic.AddInt64(&pending, 1)
+			select {
+			case signalc <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	// Deliver counted ticks.
+	go func() {
+		defer close(queuedc)
+		for {
+			for atomic.LoadInt64(&pending) > 0 {
+				select {
+				case queuedc <- struct{}{}:
+					atomic.AddInt64(&pending, -1)
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-signalc:
+			case <-ended:
+				if atomic.LoadInt64(&pending) == 0 {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return queuedc
+}
+
+//--------------------
+// PRIVATE HELPER
+//--------------------
+
+// isPaused checks if the Loop running the worker with this
+// context is paused.
+func isPaused(ctx context.Context) bool {
+	select {
+	case <-Paused(ctx):
+		return true
+	default:
+		return false
+	}
+}
+
+// EOF
@@ -0,0 +1,140 @@
+// Tideland Go Together - Loop - Unit Tests
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package loop_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/together/fuse"
+	"tideland.dev/go/together/loop"
+	"tideland.dev/go/together/wait"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestTickedOverlap tests skipping and queueing overlapping ticks.
+func TestTickedOverlap(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	run := func(overlap loop.Overlap) int32 {
+		var calls int32
+		fn := func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(25 * time.Millisecond)
+			return nil
+		}
+		l, err := loop.GoTicked(wait.MakeMaxIntervalsTicker(10*time.Millisecond, 10), fn, loop.WithOverlap(overlap))
+		assert.NoError(err)
+		assert.OK(l.Wait(fuse.Stopped, 2*time.Second))
+		assert.NoError(l.Err())
+		return atomic.LoadInt32(&calls)
+	}
+
+	// Test.
+	assert.True(run(loop.OverlapSkip) < 10)
+	assert.Equal(run(loop.OverlapQueue), int32(10))
+
+	_, err := loop.GoTicked(wait.MakeIntervalTicker(time.Second), nil, loop.WithOverlap(loop.Overlap(99)))
+	assert.ErrorMatch(err, ".*invalid loop option: overlap 99.*")
+}
+
+// TestTickedErrors tests the handling of errors and panics
+// of a ticked function.
+func TestTickedErrors(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	var calls int32
+	fn := func(ctx context.Context) error {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			panic("bam")
+		case 2:
+			return nil
+		default:
+			return errors.New("failed")
+		}
+	}
+	repaired := 0
+	repairer := func(reason interface{}) error {
+		repaired++
+		return nil
+	}
+	l, err := loop.GoTicked(wait.MakeIntervalTicker(5*time.Millisecond), fn, loop.WithRepairer(repairer))
+	assert.NoError(err)
+
+	// Test.
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+	assert.ErrorMatch(l.Err(), "failed")
+	assert.Equal(repaired, 1)
+	assert.Equal(atomic.LoadInt32(&calls), int32(3))
+}
+
+// TestTickedPause tests skipping ticks while the Loop is paused.
+func TestTickedPause(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	for _, overlap := range []loop.Overlap{loop.OverlapSkip, loop.OverlapQueue} {
+		var calls int32
+		fn := func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		}
+		l, err := loop.GoTicked(wait.MakeIntervalTicker(time.Millisecond), fn, loop.WithOverlap(overlap))
+		assert.NoError(err)
+
+		// Test.
+		assert.Retry(func() bool { return atomic.LoadInt32(&calls) > 0 }, 100, time.Millisecond)
+		l.Pause()
+		time.Sleep(10 * time.Millisecond)
+		paused := atomic.LoadInt32(&calls)
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(atomic.LoadInt32(&calls), paused)
+		l.Resume()
+		assert.Retry(func() bool { return atomic.LoadInt32(&calls) > paused }, 100, time.Millisecond)
+		assert.True(atomic.LoadInt32(&calls) < paused+10)
+		l.Stop()
+		assert.OK(l.Wait(fuse.Stopped, time.Second))
+		assert.NoError(l.Err())
+	}
+}
+
+// TestTickedWatchdog tests a ticked Loop with ticks rarer than
+// the heartbeats its watchdog expects.
+func TestTickedWatchdog(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	var calls int32
+	fn := func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}
+	l, err := loop.GoTicked(wait.MakeIntervalTicker(50*time.Millisecond), fn, loop.WithWatchdog(20*time.Millisecond))
+	assert.NoError(err)
+
+	// Test.
+	time.Sleep(120 * time.Millisecond)
+	assert.Equal(l.Status(), fuse.Working)
+	assert.NoError(l.Err())
+	assert.True(atomic.LoadInt32(&calls) >= 1)
+	l.Stop()
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+	assert.NoError(l.Err())
+}
+
+// EOF
@@ -0,0 +1,138 @@
+// Tideland Go Together - Loop
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package loop // import "tideland.dev/go/together/loop"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"tideland.dev/go/together/internal/goroutine"
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrHung is passed to the Repairer or returned by Err() when a
+// worker of a Loop with watchdog missed its heartbeat.
+var ErrHung = errors.New("loop worker hung")
+
+//--------------------
+// HEARTBEAT
+//--------------------
+
+// heartbeatKey is the context key for the heartbeat.
+type heartbeatKey struct{}
+
+// heartbeat contains the time of the last beat in nanoseconds.
+type heartbeat struct {
+	last int64
+}
+
+// beat stores the current time.
+func (hb *heartbeat) beat() {
+	atomic.StoreInt64(&hb.last, time.Now().UnixNano())
+}
+
+// since returns the duration since the last beat.
+func (hb *heartbeat) since() time.Duration {
+	return time.Duration(time.Now().UnixNano() - atomic.LoadInt64(&hb.last))
+}
+
+// Heartbeat signals the watchdog of the Loop running the worker
+// with this context that it is alive. Without watchdog it does
+// nothing.
+func Heartbeat(ctx context.Context) {
+	if hb, ok := ctx.Value(heartbeatKey{}).(*heartbeat); ok {
+		hb.beat()
+	}
+}
+
+//--------------------
+// WATCHDOG
+//--------------------
+
+// abandonedWorker is a worker goroutine abandoned by the watchdog.
+type abandonedWorker struct {
+	id       uint64
+	finished chan struct{}
+}
+
+// watch runs the worker in its own goroutine and checks its heartbeat.
+// If it's missing the worker context is cancelled and the worker is
+// abandoned with ErrHung as reason. It isn't waited for, as it may
+// never return. While the Loop is paused the heartbeat isn't checked.
+func (l *Loop) watch(ctx context.Context, cancel func()) (interface{}, []byte, error) {
+	type result struct {
+		reason interface{}
+		stack  []byte
+		err    error
+	}
+	hb := &heartbeat{}
+	hb.beat()
+	ctx = context.WithValue(ctx, heartbeatKey{}, hb)
+	resultc := make(chan result, 1)
+	idc := make(chan uint64, 1)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		idc <- goroutine.ID()
+		reason, stack, err := l.call(ctx)
+		resultc <- result{reason, stack, err}
+	}()
+	id := <-idc
+	ticker := time.NewTicker(l.watchdog / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case r := <-resultc:
+			return r.reason, r.stack, r.err
+		case <-ticker.C:
+			if l.pauser.isPaused() {
+				// A paused worker waits and doesn't beat, so
+				// the time paused doesn't count.
+				hb.beat()
+				continue
+			}
+			if hb.since() > l.watchdog {
+				cancel()
+				l.abandon(id, finished)
+				return fmt.Errorf("%w: no heartbeat within %v", ErrHung, l.watchdog), nil, nil
+			}
+		}
+	}
+}
+
+// abandon remembers the abandoned worker goroutine, so that
+// StopAndWait() can wait for it. Already finished ones are
+// dropped.
+func (l *Loop) abandon(id uint64, finished chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var running []abandonedWorker
+	for _, aw := range l.abandoned {
+		select {
+		case <-aw.finished:
+		default:
+			running = append(running, aw)
+		}
+	}
+	l.abandoned = append(running, abandonedWorker{
+		id:       id,
+		finished: finished,
+	})
+}
+
+// EOF
@@ -0,0 +1,207 @@
+// Tideland Go Together - Loop - Unit Tests
+//
+// Copyright (C) 2017-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package loop_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+	"tideland.dev/go/together/fuse"
+	"tideland.dev/go/together/loop"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestWatchdogHeartbeat tests a worker with regular heartbeats.
+func TestWatchdogHeartbeat(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	beats := make(chan struct{})
+	worker := func(ctx context.Context) error {
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				loop.Heartbeat(ctx)
+				select {
+				case beats <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+	l, err := loop.Go(worker, loop.WithWatchdog(20*time.Millisecond))
+	assert.NoError(err)
+
+	// Test.
+	for i := 0; i < 20; i++ {
+		<-beats
+	}
+	assert.Equal(l.Status(), fuse.Working)
+	l.Stop()
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+	assert.NoError(l.Err())
+
+	_, err = loop.Go(worker, loop.WithWatchdog(0))
+	assert.ErrorMatch(err, ".*invalid loop option: watchdog interval 0s.*")
+}
+
+// TestWatchdogPause tests that a paused worker without
+// heartbeats isn't treated as hung.
+func TestWatchdogPause(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	states := make(chan string)
+	worker := func(ctx context.Context) error {
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-loop.Paused(ctx):
+				states <- "paused"
+				if err := loop.WaitResumed(ctx); err != nil {
+					return nil
+				}
+				states <- "resumed"
+			case <-time.After(5 * time.Millisecond):
+				loop.Heartbeat(ctx)
+			}
+		}
+	}
+	l, err := loop.Go(worker, loop.WithWatchdog(20*time.Millisecond))
+	assert.NoError(err)
+
+	// Test.
+	l.Pause()
+	assert.Equal(<-states, "paused")
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(l.Status(), fuse.Working)
+	assert.NoError(l.Err())
+	l.Resume()
+	assert.Equal(<-states, "resumed")
+	l.Stop()
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+	assert.NoError(l.Err())
+}
+
+// TestWatchdogHung tests ending a Loop with a hung worker.
+func TestWatchdogHung(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	blocker := make(chan struct{})
+	defer close(blocker)
+	worker := func(ctx context.Context) error {
+		// Blocking call ignoring the context.
+		<-blocker
+		return nil
+	}
+	l, err := loop.Go(worker, loop.WithWatchdog(20*time.Millisecond))
+	assert.NoError(err)
+
+	// Test.
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+	assert.True(errors.Is(l.Err(), loop.ErrHung))
+}
+
+// TestWatchdogRepair tests repairing a hung worker.
+func TestWatchdogRepair(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	runs := make(chan int32, 2)
+	var run int32
+	worker := func(ctx context.Context) error {
+		n := atomic.AddInt32(&run, 1)
+		runs <- n
+		if n == 1 {
+			// Hang until cancelled.
+			<-ctx.Done()
+			return nil
+		}
+		<-ctx.Done()
+		return errors.New("stopped")
+	}
+	repaired := make(chan interface{}, 1)
+	repairer := func(reason interface{}) error {
+		repaired <- reason
+		return nil
+	}
+	l, err := loop.Go(worker,
+		loop.WithWatchdog(20*time.Millisecond),
+		loop.WithRepairer(repairer),
+	)
+	assert.NoError(err)
+
+	// Test.
+	assert.Equal(<-runs, int32(1))
+	reason := <-repaired
+	assert.True(errors.Is(reason.(error), loop.ErrHung))
+	assert.Equal(<-runs, int32(2))
+	l.Stop()
+	assert.OK(l.Wait(fuse.Stopped, time.Second))
+	assert.ErrorMatch(l.Err(), "stopped")
+}
+
+// TestWatchdogStopAndWait tests the reporting of a worker abandoned
+// by the watchdog when stopping the Loop.
+func TestWatchdogStopAndWait(t *testing.T) {
+	// Init.
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	blocker := make(chan struct{})
+	runs := make(chan int32, 2)
+	var run int32
+	worker := func(ctx context.Context) error {
+		n := atomic.AddInt32(&run, 1)
+		runs <- n
+		if n == 1 {
+			// Blocking call ignoring the context.
+			<-blocker
+			return nil
+		}
+		<-ctx.Done()
+		return nil
+	}
+	repairer := func(reason interface{}) error {
+		return nil
+	}
+	l, err := loop.Go(worker,
+		loop.WithWatchdog(20*time.Millisecond),
+		loop.WithRepairer(repairer),
+	)
+	assert.NoError(err)
+
+	// Test.
+	assert.Equal(<-runs, int32(1))
+	assert.Equal(<-runs, int32(2))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = l.StopAndWait(ctx)
+	var aerr *loop.AbandonedError
+	assert.True(errors.As(err, &aerr))
+	assert.Contains("TestWatchdogStopAndWait", string(aerr.Stack))
+	assert.Equal(l.Status(), fuse.Stopped)
+
+	close(blocker)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(l.StopAndWait(ctx))
+}
+
+// EOF
@@ -0,0 +1,49 @@
+// Tideland Go Together - Persistent
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+// Package persistent provides an event-sourced actor. It owns the state
+// of a Model and changes it only by commands. The Model handles each
+// command and returns events which are appended to a Journal before they
+// are applied to the state.
+//
+//     type Account struct {
+//         Balance int `json:"balance"`
+//     }
+//
+//     func (a *Account) Handle(command interface{}) ([]persistent.Event, error) {
+//         amount := command.(int)
+//         if a.Balance+amount < 0 {
+//             return nil, errors.New("insufficient balance")
+//         }
+//         evt, err := persistent.NewEvent("booked", amount)
+//         return []persistent.Event{evt}, err
+//     }
+//
+//     func (a *Account) Apply(evt persistent.Event) error {
+//         var amount int
+//         if err := evt.Decode(&amount); err != nil {
+//             return err
+//         }
+//         a.Balance += amount
+//         return nil
+//     }
+//
+// Snapshot() and Restore() of the Model serialize the state. When starting
+// the actor restores the latest snapshot of the Journal and replays the
+// events appended afterwards. Periodic snapshots configured with
+// WithSnapshotEvery() bound the replay time.
+//
+//     journal, err := persistent.NewFileJournal("/var/lib/myapp/account")
+//     ...
+//     account, err := persistent.Go(&Account{}, journal, persistent.WithSnapshotEvery(1000))
+//     ...
+//     err = account.Command(100)
+//
+// The included FileJournal stores the events as JSON lines in a directory.
+package persistent // import "tideland.dev/go/together/persistent"
+
+// EOF
@@ -0,0 +1,257 @@
+// Tideland Go Together - Persistent
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package persistent // import "tideland.dev/go/together/persistent"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+const (
+	// eventsFile is the name of the events file of a FileJournal.
+	eventsFile = "events.jsonl"
+
+	// snapshotFile is the name of the snapshot file of a FileJournal.
+	snapshotFile = "snapshot.json"
+)
+
+//--------------------
+// JOURNAL
+//--------------------
+
+// Journal stores the events and snapshots of an Actor.
+type Journal interface {
+	// Append adds the events to the journal.
+	Append(events ...Event) error
+
+	// Replay calls apply for all events with a sequence number
+	// higher than after in their order.
+	Replay(after uint64, apply func(evt Event) error) error
+
+	// SaveSnapshot stores the snapshot. Events up to the sequence
+	// number of the snapshot may be dropped afterwards.
+	SaveSnapshot(snapshot Snapshot) error
+
+	// LoadSnapshot returns the latest snapshot. The bool is false
+	// if none has been saved yet.
+	LoadSnapshot() (Snapshot, bool, error)
+
+	// Close closes the journal.
+	Close() error
+}
+
+//--------------------
+// FILE JOURNAL
+//--------------------
+
+// FileJournal stores the events as JSON lines and the latest snapshot
+// as JSON file in a directory. Saving a snapshot truncates the events.
+type FileJournal struct {
+	mu     sync.Mutex
+	dir    string
+	events *os.File
+}
+
+// NewFileJournal opens or creates a journal in the directory.
+func NewFileJournal(dir string) (*FileJournal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, failure.Annotate(err, "cannot create journal directory")
+	}
+	events, err := os.OpenFile(filepath.Join(dir, eventsFile), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, failure.Annotate(err, "cannot open events file")
+	}
+	return &FileJournal{
+		dir:    dir,
+		events: events,
+	}, nil
+}
+
+// Append implements Journal. If writing fails the events file
+// is cut back to its former size.
+func (j *FileJournal) Append(events ...Event) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var buf []byte
+	for _, evt := range events {
+		bs, err := json.Marshal(evt)
+		if err != nil {
+			return failure.Annotate(err, "cannot marshal event")
+		}
+		buf = append(append(buf, bs...), '\n')
+	}
+	fi, err := j.events.Stat()
+	if err != nil {
+		return failure.Annotate(err, "cannot stat events file")
+	}
+	if _, err := j.events.Write(buf); err != nil {
+		return failure.Collect(
+			failure.Annotate(err, "cannot write events"),
+			j.events.Truncate(fi.Size()),
+		)
+	}
+	if err := j.events.Sync(); err != nil {
+		return failure.Collect(
+			failure.Annotate(err, "cannot sync events"),
+			j.events.Truncate(fi.Size()),
+		)
+	}
+	return nil
+}
+
+// Replay implements Journal. A torn last event without its
+// newline, e.g. after a crash during writing, is ignored and
+// cut off.
+func (j *FileJournal) Replay(after uint64, apply func(evt Event) error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f, err := os.Open(filepath.Join(j.dir, eventsFile))
+	if err != nil {
+		return failure.Annotate(err, "cannot open events file")
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	offset := int64(0)
+	for {
+		line, err := r.ReadBytes('\n')
+		switch {
+		case err == io.EOF && len(line) == 0:
+			return nil
+		case err == io.EOF:
+			// Keep the file ending with the newline of
+			// the last complete event.
+			if err := j.events.Truncate(offset); err != nil {
+				return failure.Annotate(err, "cannot cut off torn event")
+			}
+			return nil
+		case err != nil:
+			return failure.Annotate(err, "cannot read events")
+		}
+		offset += int64(len(line))
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var evt Event
+		if err := json.Unmarshal(line, &evt); err != nil {
+			return failure.Annotate(err, "cannot unmarshal event")
+		}
+		if evt.Sequence <= after {
+			continue
+		}
+		if err := apply(evt); err != nil {
+			return err
+		}
+	}
+}
+
+// SaveSnapshot implements Journal.
+func (j *FileJournal) SaveSnapshot(snapshot Snapshot) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	bs, err := json.Marshal(snapshot)
+	if err != nil {
+		return failure.Annotate(err, "cannot marshal snapshot")
+	}
+	// Write temporary file first and then rename it, so that
+	// always a complete snapshot exists. Both have to be on
+	// disk before the events are dropped.
+	tmp := filepath.Join(j.dir, snapshotFile+".tmp")
+	if err := writeFileSync(tmp, bs); err != nil {
+		return failure.Annotate(err, "cannot write snapshot")
+	}
+	if err := os.Rename(tmp, filepath.Join(j.dir, snapshotFile)); err != nil {
+		return failure.Annotate(err, "cannot rename snapshot")
+	}
+	if err := syncDir(j.dir); err != nil {
+		return failure.Annotate(err, "cannot sync journal directory")
+	}
+	// Events are covered by the snapshot now.
+	if err := j.events.Truncate(0); err != nil {
+		return failure.Annotate(err, "cannot truncate events")
+	}
+	return nil
+}
+
+// LoadSnapshot implements Journal.
+func (j *FileJournal) LoadSnapshot() (Snapshot, bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	bs, err := ioutil.ReadFile(filepath.Join(j.dir, snapshotFile))
+	if os.IsNotExist(err) {
+		return Snapshot{}, false, nil
+	}
+	if err != nil {
+		return Snapshot{}, false, failure.Annotate(err, "cannot read snapshot")
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(bs, &snapshot); err != nil {
+		return Snapshot{}, false, failure.Annotate(err, "cannot unmarshal snapshot")
+	}
+	return snapshot, true, nil
+}
+
+// Close implements Journal.
+func (j *FileJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.events.Close()
+}
+
+//--------------------
+// PRIVATE HELPER
+//--------------------
+
+// writeFileSync writes the data into the named file and
+// syncs it to disk.
+func writeFileSync(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir syncs the directory, so that renamed files
+// are on disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+// EOF
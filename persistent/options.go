@@ -0,0 +1,47 @@
+// Tideland Go Together - Persistent
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package persistent // import "tideland.dev/go/together/persistent"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"tideland.dev/go/trace/failure"
+
+	"tideland.dev/go/together/actor"
+)
+
+//--------------------
+// OPTIONS
+//--------------------
+
+// Option defines the signature of an option setting function.
+type Option func(pa *Actor) error
+
+// WithSnapshotEvery lets the Actor save a snapshot each time the
+// given number of events has been appended since the last one.
+func WithSnapshotEvery(events int) Option {
+	return func(pa *Actor) error {
+		if events < 1 {
+			return failure.New("invalid persistent option: snapshot every %d events", events)
+		}
+		pa.snapshotEvery = events
+		return nil
+	}
+}
+
+// WithActorOptions passes options to the underlying actor.
+func WithActorOptions(options ...actor.Option) Option {
+	return func(pa *Actor) error {
+		pa.actorOptions = append(pa.actorOptions, options...)
+		return nil
+	}
+}
+
+// EOF
@@ -0,0 +1,291 @@
+// Tideland Go Together - Persistent
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package persistent // import "tideland.dev/go/together/persistent"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"encoding/json"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+
+	"tideland.dev/go/together/actor"
+)
+
+//--------------------
+// EVENT
+//--------------------
+
+// Event describes one change of the state. Sequence and Time are set
+// by the Actor when the event is appended to the Journal.
+type Event struct {
+	Sequence uint64          `json:"sequence"`
+	Time     time.Time       `json:"time"`
+	Topic    string          `json:"topic"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+}
+
+// NewEvent creates an event with the topic and the payload
+// marshalled into JSON. The payload is optional.
+func NewEvent(topic string, payload interface{}) (Event, error) {
+	if topic == "" {
+		return Event{}, failure.New("event needs topic")
+	}
+	evt := Event{
+		Topic: topic,
+	}
+	if payload != nil {
+		bs, err := json.Marshal(payload)
+		if err != nil {
+			return Event{}, failure.Annotate(err, "cannot marshal payload")
+		}
+		evt.Payload = bs
+	}
+	return evt, nil
+}
+
+// Decode unmarshals the payload into the passed value.
+func (evt Event) Decode(v interface{}) error {
+	if len(evt.Payload) == 0 {
+		return failure.New("event '%s' has no payload", evt.Topic)
+	}
+	if err := json.Unmarshal(evt.Payload, v); err != nil {
+		return failure.Annotate(err, "cannot unmarshal payload")
+	}
+	return nil
+}
+
+//--------------------
+// SNAPSHOT
+//--------------------
+
+// Snapshot contains the serialized state after the event with
+// the sequence number.
+type Snapshot struct {
+	Sequence uint64          `json:"sequence"`
+	Time     time.Time       `json:"time"`
+	State    json.RawMessage `json:"state"`
+}
+
+//--------------------
+// MODEL
+//--------------------
+
+// Model is the state owned by the Actor. All methods are called
+// on the goroutine of the Actor.
+type Model interface {
+	// Handle checks the command against the current state and
+	// returns the events describing the wanted changes.
+	Handle(command interface{}) ([]Event, error)
+
+	// Apply changes the state by the event. It is called for new
+	// events as well as during replay. As the events are already
+	// journaled an error stops the Actor, so all checks belong
+	// into Handle. A failing Append of the Journal stops the Actor
+	// too.
+	Apply(evt Event) error
+
+	// Snapshot returns the serialized state.
+	Snapshot() (json.RawMessage, error)
+
+	// Restore sets the state from a snapshot.
+	Restore(state json.RawMessage) error
+}
+
+//--------------------
+// ACTOR
+//--------------------
+
+// Actor changes the state of a Model only by commands and journals
+// the resulting events.
+type Actor struct {
+	act           *actor.Actor
+	model         Model
+	journal       Journal
+	actorOptions  []actor.Option
+	snapshotEvery int
+	sequence      uint64
+	unsnapshotted int
+	broken        error
+}
+
+// Go recovers the state of the model from the journal and starts
+// the Actor.
+func Go(model Model, journal Journal, options ...Option) (*Actor, error) {
+	pa := &Actor{
+		model:   model,
+		journal: journal,
+	}
+	for _, option := range options {
+		if err := option(pa); err != nil {
+			return nil, err
+		}
+	}
+	if err := pa.recover(); err != nil {
+		return nil, err
+	}
+	// Let the actor terminate with the error of an event
+	// that could not be applied.
+	pa.actorOptions = append(pa.actorOptions, actor.WithFinalizer(func(err error) error {
+		return failure.First(err, pa.broken)
+	}))
+	act, err := actor.Go(pa.actorOptions...)
+	if err != nil {
+		return nil, err
+	}
+	pa.act = act
+	return pa, nil
+}
+
+// Command lets the model handle the command, appends the returned
+// events to the journal, and applies them to the model.
+func (pa *Actor) Command(command interface{}) error {
+	var err error
+	if aerr := pa.act.DoSync(func() {
+		err = pa.handle(command)
+	}); aerr != nil {
+		return aerr
+	}
+	return err
+}
+
+// Query executes the function with the model on the goroutine of
+// the Actor. The model must not be changed.
+func (pa *Actor) Query(query func(model Model) error) error {
+	var err error
+	if aerr := pa.act.DoSync(func() {
+		err = query(pa.model)
+	}); aerr != nil {
+		return aerr
+	}
+	return err
+}
+
+// Snapshot saves a snapshot of the model in the journal.
+func (pa *Actor) Snapshot() error {
+	var err error
+	if aerr := pa.act.DoSync(func() {
+		err = pa.snapshot()
+	}); aerr != nil {
+		return aerr
+	}
+	return err
+}
+
+// Sequence returns the sequence number of the last event.
+func (pa *Actor) Sequence() (uint64, error) {
+	var sequence uint64
+	if err := pa.act.DoSync(func() {
+		sequence = pa.sequence
+	}); err != nil {
+		return 0, err
+	}
+	return sequence, nil
+}
+
+// Err returns information if the Actor has an error.
+func (pa *Actor) Err() error {
+	return pa.act.Err()
+}
+
+// Stop terminates the Actor and closes the journal.
+func (pa *Actor) Stop() error {
+	pa.act.Stop()
+	err := <-pa.act.Monitor()
+	return failure.First(err, pa.journal.Close())
+}
+
+// recover restores the latest snapshot and replays the
+// events after it.
+func (pa *Actor) recover() error {
+	snapshot, ok, err := pa.journal.LoadSnapshot()
+	if err != nil {
+		return failure.Annotate(err, "cannot load snapshot")
+	}
+	if ok {
+		if err := pa.model.Restore(snapshot.State); err != nil {
+			return failure.Annotate(err, "cannot restore snapshot")
+		}
+		pa.sequence = snapshot.Sequence
+	}
+	err = pa.journal.Replay(pa.sequence, func(evt Event) error {
+		if err := pa.model.Apply(evt); err != nil {
+			return err
+		}
+		pa.sequence = evt.Sequence
+		pa.unsnapshotted++
+		return nil
+	})
+	if err != nil {
+		return failure.Annotate(err, "cannot replay events")
+	}
+	return nil
+}
+
+// handle processes a command on the goroutine of the Actor.
+func (pa *Actor) handle(command interface{}) error {
+	events, err := pa.model.Handle(command)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	for i := range events {
+		events[i].Sequence = pa.sequence + uint64(i) + 1
+		events[i].Time = now
+	}
+	if err := pa.journal.Append(events...); err != nil {
+		// Events may have been written anyway, so the
+		// Actor cannot continue.
+		pa.broken = failure.Annotate(err, "cannot append events")
+		pa.act.Stop()
+		return pa.broken
+	}
+	for _, evt := range events {
+		if err := pa.model.Apply(evt); err != nil {
+			// Journal and state differ now, so the
+			// Actor cannot continue.
+			pa.broken = failure.Annotate(err, "cannot apply event %d", evt.Sequence)
+			pa.act.Stop()
+			return pa.broken
+		}
+		pa.sequence = evt.Sequence
+		pa.unsnapshotted++
+	}
+	if pa.snapshotEvery > 0 && pa.unsnapshotted >= pa.snapshotEvery {
+		// The events are durable, so the command succeeded
+		// even without snapshot. It is tried again with the
+		// next command.
+		_ = pa.snapshot()
+	}
+	return nil
+}
+
+// snapshot saves the state of the model.
+func (pa *Actor) snapshot() error {
+	state, err := pa.model.Snapshot()
+	if err != nil {
+		return failure.Annotate(err, "cannot create snapshot")
+	}
+	if err := pa.journal.SaveSnapshot(Snapshot{
+		Sequence: pa.sequence,
+		Time:     time.Now().UTC(),
+		State:    state,
+	}); err != nil {
+		return failure.Annotate(err, "cannot save snapshot")
+	}
+	pa.unsnapshotted = 0
+	return nil
+}
+
+// EOF
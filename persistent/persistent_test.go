@@ -0,0 +1,310 @@
+// Tideland Go Together - Persistent - Unit Tests
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package persistent_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/together/persistent"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestCommand tests handling commands and querying the model.
+func TestCommand(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	journal, err := persistent.NewFileJournal(t.TempDir())
+	assert.OK(err)
+	pa, err := persistent.Go(&account{}, journal)
+	assert.OK(err)
+
+	assert.OK(pa.Command(100))
+	assert.OK(pa.Command(-30))
+	assert.ErrorMatch(pa.Command(-100), "insufficient balance")
+	assert.Equal(balance(assert, pa), 70)
+	seq, err := pa.Sequence()
+	assert.OK(err)
+	assert.Equal(seq, uint64(2))
+
+	assert.OK(pa.Stop())
+}
+
+// TestReplay tests the recovery of the state after a restart.
+func TestReplay(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir := t.TempDir()
+	journal, err := persistent.NewFileJournal(dir)
+	assert.OK(err)
+	pa, err := persistent.Go(&account{}, journal)
+	assert.OK(err)
+	for i := 1; i <= 10; i++ {
+		assert.OK(pa.Command(i))
+	}
+	assert.OK(pa.Stop())
+
+	journal, err = persistent.NewFileJournal(dir)
+	assert.OK(err)
+	pa, err = persistent.Go(&account{}, journal)
+	assert.OK(err)
+	assert.Equal(balance(assert, pa), 55)
+	seq, err := pa.Sequence()
+	assert.OK(err)
+	assert.Equal(seq, uint64(10))
+	assert.OK(pa.Command(5))
+	assert.Equal(balance(assert, pa), 60)
+	assert.OK(pa.Stop())
+}
+
+// TestSnapshots tests the periodic snapshots and the replay
+// of the events appended afterwards.
+func TestSnapshots(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir := t.TempDir()
+	journal, err := persistent.NewFileJournal(dir)
+	assert.OK(err)
+	pa, err := persistent.Go(&account{}, journal, persistent.WithSnapshotEvery(4))
+	assert.OK(err)
+	for i := 1; i <= 10; i++ {
+		assert.OK(pa.Command(i))
+	}
+	assert.OK(pa.Stop())
+
+	// Snapshot after event 8, events 9 and 10 are left.
+	snapshot, ok, err := journal.LoadSnapshot()
+	assert.OK(err)
+	assert.True(ok)
+	assert.Equal(snapshot.Sequence, uint64(8))
+	count := 0
+	journal, err = persistent.NewFileJournal(dir)
+	assert.OK(err)
+	assert.OK(journal.Replay(0, func(evt persistent.Event) error {
+		count++
+		return nil
+	}))
+	assert.Equal(count, 2)
+
+	pa, err = persistent.Go(&account{}, journal)
+	assert.OK(err)
+	assert.Equal(balance(assert, pa), 55)
+	assert.OK(pa.Snapshot())
+	assert.OK(pa.Stop())
+
+	_, err = persistent.Go(&account{}, journal, persistent.WithSnapshotEvery(0))
+	assert.ErrorMatch(err, ".*invalid persistent option.*")
+}
+
+// TestTornEvent tests the replay of a journal with an
+// incompletely written last event.
+func TestTornEvent(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir := t.TempDir()
+	journal, err := persistent.NewFileJournal(dir)
+	assert.OK(err)
+	pa, err := persistent.Go(&account{}, journal)
+	assert.OK(err)
+	assert.OK(pa.Command(10))
+	assert.OK(pa.Command(20))
+	assert.OK(pa.Stop())
+
+	f, err := os.OpenFile(filepath.Join(dir, "events.jsonl"), os.O_APPEND|os.O_WRONLY, 0644)
+	assert.OK(err)
+	_, err = f.WriteString(`{"sequence":3,"topic":"boo`)
+	assert.OK(err)
+	assert.OK(f.Close())
+
+	journal, err = persistent.NewFileJournal(dir)
+	assert.OK(err)
+	pa, err = persistent.Go(&account{}, journal)
+	assert.OK(err)
+	assert.Equal(balance(assert, pa), 30)
+	assert.OK(pa.Command(5))
+	assert.OK(pa.Stop())
+
+	// Torn event has been cut off and the events
+	// are still valid JSON lines.
+	bs, err := ioutil.ReadFile(filepath.Join(dir, "events.jsonl"))
+	assert.OK(err)
+	lines := strings.Split(string(bs), "\n")
+	assert.Length(lines, 4)
+	for _, line := range lines[:3] {
+		assert.True(json.Valid([]byte(line)))
+	}
+	assert.Equal(lines[3], "")
+
+	journal, err = persistent.NewFileJournal(dir)
+	assert.OK(err)
+	pa, err = persistent.Go(&account{}, journal)
+	assert.OK(err)
+	assert.Equal(balance(assert, pa), 35)
+	assert.OK(pa.Stop())
+}
+
+// TestApplyError tests the stopping of an Actor whose model
+// cannot apply an appended event.
+func TestApplyError(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	journal, err := persistent.NewFileJournal(t.TempDir())
+	assert.OK(err)
+	pa, err := persistent.Go(&brittleAccount{}, journal)
+	assert.OK(err)
+	assert.OK(pa.Command(10))
+	assert.ErrorMatch(pa.Command(13), ".*cannot apply event 2.*unlucky.*")
+	assert.NotNil(pa.Command(5))
+	assert.ErrorMatch(pa.Stop(), ".*cannot apply event 2.*unlucky.*")
+}
+
+// TestAppendError tests the stopping of an Actor whose journal
+// fails after writing the events.
+func TestAppendError(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir := t.TempDir()
+	fj, err := persistent.NewFileJournal(dir)
+	assert.OK(err)
+	journal := &failingJournal{FileJournal: fj}
+	pa, err := persistent.Go(&account{}, journal)
+	assert.OK(err)
+	assert.OK(pa.Command(10))
+	journal.fail = true
+	assert.ErrorMatch(pa.Command(5), ".*cannot append events.*sync failed.*")
+	assert.NotNil(pa.Command(1))
+	assert.ErrorMatch(pa.Stop(), ".*cannot append events.*sync failed.*")
+
+	// Written event is replayed and sequence continues.
+	fj, err = persistent.NewFileJournal(dir)
+	assert.OK(err)
+	pa, err = persistent.Go(&account{}, fj)
+	assert.OK(err)
+	assert.Equal(balance(assert, pa), 15)
+	assert.OK(pa.Command(1))
+	sequence, err := pa.Sequence()
+	assert.OK(err)
+	assert.Equal(sequence, uint64(3))
+	assert.OK(pa.Stop())
+}
+
+// TestSnapshotError tests that a failing automatic snapshot
+// doesn't fail the command.
+func TestSnapshotError(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	journal, err := persistent.NewFileJournal(t.TempDir())
+	assert.OK(err)
+	pa, err := persistent.Go(&brittleAccount{}, journal, persistent.WithSnapshotEvery(1))
+	assert.OK(err)
+	assert.OK(pa.Command(10))
+	assert.OK(pa.Command(20))
+	sequence, err := pa.Sequence()
+	assert.OK(err)
+	assert.Equal(sequence, uint64(2))
+	assert.ErrorMatch(pa.Snapshot(), ".*cannot create snapshot.*")
+	assert.OK(pa.Stop())
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// account is a simple model for the tests.
+type account struct {
+	Balance int `json:"balance"`
+}
+
+func (a *account) Handle(command interface{}) ([]persistent.Event, error) {
+	amount, ok := command.(int)
+	if !ok {
+		return nil, errors.New("invalid command")
+	}
+	if a.Balance+amount < 0 {
+		return nil, errors.New("insufficient balance")
+	}
+	evt, err := persistent.NewEvent("booked", amount)
+	if err != nil {
+		return nil, err
+	}
+	return []persistent.Event{evt}, nil
+}
+
+func (a *account) Apply(evt persistent.Event) error {
+	var amount int
+	if err := evt.Decode(&amount); err != nil {
+		return err
+	}
+	a.Balance += amount
+	return nil
+}
+
+func (a *account) Snapshot() (json.RawMessage, error) {
+	return json.Marshal(a)
+}
+
+func (a *account) Restore(state json.RawMessage) error {
+	return json.Unmarshal(state, a)
+}
+
+// brittleAccount is an account failing to apply
+// bookings of 13 and to create snapshots.
+type brittleAccount struct {
+	account
+}
+
+func (a *brittleAccount) Apply(evt persistent.Event) error {
+	var amount int
+	if err := evt.Decode(&amount); err != nil {
+		return err
+	}
+	if amount == 13 {
+		return errors.New("unlucky")
+	}
+	return a.account.Apply(evt)
+}
+
+func (a *brittleAccount) Snapshot() (json.RawMessage, error) {
+	return nil, errors.New("no snapshot")
+}
+
+// failingJournal is a FileJournal failing after
+// writing the events if wanted.
+type failingJournal struct {
+	*persistent.FileJournal
+	fail bool
+}
+
+func (j *failingJournal) Append(events ...persistent.Event) error {
+	if err := j.FileJournal.Append(events...); err != nil {
+		return err
+	}
+	if j.fail {
+		return errors.New("sync failed")
+	}
+	return nil
+}
+
+// balance queries the balance of the account.
+func balance(assert *asserts.Asserts, pa *persistent.Actor) int {
+	var b int
+	assert.OK(pa.Query(func(model persistent.Model) error {
+		b = model.(*account).Balance
+		return nil
+	}))
+	return b
+}
+
+// EOF
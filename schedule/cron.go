@@ -0,0 +1,293 @@
+// Tideland Go Together - Schedule
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package schedule // import "tideland.dev/go/together/schedule"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+
+	"tideland.dev/go/together/wait"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// macros contains the predefined expressions.
+var macros = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// monthNames maps the month names to their numbers.
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+// dayNames maps the weekday names to their numbers.
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// searchYears limits the search for the next time.
+const searchYears = 5
+
+//--------------------
+// CRON
+//--------------------
+
+// Cron is a parsed cron expression.
+type Cron struct {
+	expr     string
+	location *time.Location
+	seconds  bits
+	minutes  bits
+	hours    bits
+	days     bits
+	months   bits
+	weekdays bits
+	dayStar  bool
+	wdayStar bool
+}
+
+// Parse parses a cron expression with five fields (minute, hour,
+// day of month, month, day of week) or six fields with the seconds
+// first. A prefix CRON_TZ=<zone> sets the time zone, otherwise the
+// location is used. If it is nil it's time.Local.
+func Parse(expr string, location *time.Location) (*Cron, error) {
+	if location == nil {
+		location = time.Local
+	}
+	c := &Cron{
+		expr:     expr,
+		location: location,
+	}
+	spec := strings.TrimSpace(expr)
+	// Time zone.
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if strings.HasPrefix(spec, prefix) {
+			i := strings.IndexAny(spec, " \t")
+			if i < 0 {
+				return nil, failure.New("invalid cron expression %q: missing fields", expr)
+			}
+			loc, err := time.LoadLocation(spec[len(prefix):i])
+			if err != nil {
+				return nil, failure.Annotate(err, "invalid cron expression %q", expr)
+			}
+			c.location = loc
+			spec = strings.TrimSpace(spec[i:])
+			break
+		}
+	}
+	// Macros and fields.
+	if macro, ok := macros[spec]; ok {
+		spec = macro
+	}
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, failure.New("invalid cron expression %q: need 5 or 6 fields", expr)
+	}
+	var err error
+	parsers := []struct {
+		field *bits
+		min   int
+		max   int
+		names map[string]int
+	}{
+		{&c.seconds, 0, 59, nil},
+		{&c.minutes, 0, 59, nil},
+		{&c.hours, 0, 23, nil},
+		{&c.days, 1, 31, nil},
+		{&c.months, 1, 12, monthNames},
+		{&c.weekdays, 0, 7, dayNames},
+	}
+	for i, p := range parsers {
+		if *p.field, err = parseField(fields[i], p.min, p.max, p.names); err != nil {
+			return nil, failure.Annotate(err, "invalid cron expression %q", expr)
+		}
+	}
+	// Sunday is 0 and 7.
+	if c.weekdays.has(7) {
+		c.weekdays |= 1
+	}
+	c.dayStar = strings.HasPrefix(fields[3], "*") || fields[3] == "?"
+	c.wdayStar = strings.HasPrefix(fields[5], "*") || fields[5] == "?"
+	return c, nil
+}
+
+// String returns the original expression.
+func (c *Cron) String() string {
+	return c.expr
+}
+
+// Location returns the time zone of the Cron.
+func (c *Cron) Location() *time.Location {
+	return c.location
+}
+
+// Next returns the first time after t matching the Cron. If there's
+// none within the next years the zero time is returned.
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.In(c.location).Truncate(time.Second).Add(time.Second)
+	limit := t.Year() + searchYears
+WRAP:
+	for t.Year() <= limit {
+		for !c.months.has(int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.location)
+			if t.Month() == time.January {
+				continue WRAP
+			}
+		}
+		for !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.location)
+			if t.Day() == 1 {
+				continue WRAP
+			}
+		}
+		for !c.hours.has(t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.location)
+			if t.Hour() == 0 {
+				continue WRAP
+			}
+		}
+		for !c.minutes.has(t.Minute()) {
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			if t.Minute() == 0 {
+				continue WRAP
+			}
+		}
+		for !c.seconds.has(t.Second()) {
+			t = t.Add(time.Second)
+			if t.Second() == 0 {
+				continue WRAP
+			}
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// Ticker returns a wait.Ticker signalling at the times of the Cron.
+// It ends if there's no next time.
+func (c *Cron) Ticker() wait.Ticker {
+	return func(ctx context.Context) <-chan struct{} {
+		var last time.Time
+		changer := func(_ time.Duration) (time.Duration, bool) {
+			now := time.Now()
+			from := now
+			if last.After(from) {
+				from = last
+			}
+			next := c.Next(from)
+			if next.IsZero() {
+				return 0, false
+			}
+			last = next
+			return next.Sub(now), true
+		}
+		return wait.MakeGenericIntervalTicker(changer)(ctx)
+	}
+}
+
+// dayMatches checks day of month and day of week. If both are
+// restricted one of them has to match.
+func (c *Cron) dayMatches(t time.Time) bool {
+	day := c.days.has(t.Day())
+	wday := c.weekdays.has(int(t.Weekday()))
+	if c.dayStar || c.wdayStar {
+		return day && wday
+	}
+	return day || wday
+}
+
+//--------------------
+// PRIVATE HELPER
+//--------------------
+
+// bits contains the allowed values of a field.
+type bits uint64
+
+// has checks if the value is allowed.
+func (b bits) has(v int) bool {
+	return b&(1<<uint(v)) != 0
+}
+
+// parseField parses one field of an expression.
+func parseField(field string, min, max int, names map[string]int) (bits, error) {
+	var b bits
+	for _, part := range strings.Split(field, ",") {
+		lo, hi, step := min, max, 1
+		rng := part
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rng = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, failure.New("invalid step in %q", part)
+			}
+		}
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			i := strings.Index(rng, "-")
+			var err error
+			if lo, err = parseValue(rng[:i], names); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(rng[i+1:], names); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if lo, err = parseValue(rng, names); err != nil {
+				return 0, err
+			}
+			if step == 1 {
+				hi = lo
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, failure.New("value out of range in %q", part)
+		}
+		for v := lo; v <= hi; v += step {
+			b |= 1 << uint(v)
+		}
+	}
+	return b, nil
+}
+
+// parseValue parses a number or a name.
+func parseValue(value string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(value)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, failure.New("invalid value %q", value)
+	}
+	return v, nil
+}
+
+// EOF
@@ -0,0 +1,108 @@
+// Tideland Go Together - Schedule - Unit Tests
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package schedule_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/together/schedule"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestCronNext tests the calculation of the next times.
+func TestCronNext(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	from := time.Date(2021, time.March, 15, 10, 20, 30, 0, time.UTC) // Monday
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2021, time.March, 15, 10, 21, 0, 0, time.UTC)},
+		{"* * * * * *", time.Date(2021, time.March, 15, 10, 20, 31, 0, time.UTC)},
+		{"*/15 * * * * *", time.Date(2021, time.March, 15, 10, 20, 45, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2021, time.March, 16, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * MON-FRI", time.Date(2021, time.March, 15, 12, 0, 0, 0, time.UTC)},
+		{"0 12 * * sat,sun", time.Date(2021, time.March, 20, 12, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, time.March, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2021, time.March, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 10-12/2 * * *", time.Date(2021, time.March, 15, 12, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, time.March, 15, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2021, time.March, 21, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		c, err := schedule.Parse(test.expr, time.UTC)
+		assert.NoError(err, test.expr)
+		assert.Equal(c.Next(from), test.next, test.expr)
+	}
+
+	c, err := schedule.Parse("0 0 30 2 *", time.UTC)
+	assert.NoError(err)
+	assert.True(c.Next(from).IsZero())
+}
+
+// TestCronLocation tests expressions in time zones.
+func TestCronLocation(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(err)
+	from := time.Date(2021, time.March, 15, 10, 0, 0, 0, time.UTC)
+
+	c, err := schedule.Parse("0 12 * * *", berlin)
+	assert.NoError(err)
+	assert.Equal(c.Next(from).UTC(), time.Date(2021, time.March, 15, 11, 0, 0, 0, time.UTC))
+	c, err = schedule.Parse("CRON_TZ=Asia/Tokyo 0 12 * * *", berlin)
+	assert.NoError(err)
+	assert.Equal(c.Location().String(), "Asia/Tokyo")
+	assert.Equal(c.Next(from).UTC(), time.Date(2021, time.March, 16, 3, 0, 0, 0, time.UTC))
+
+	// Daylight saving time starts at 2021-03-28 02:00 in Berlin.
+	c, err = schedule.Parse("30 2 * * *", berlin)
+	assert.NoError(err)
+	next := c.Next(time.Date(2021, time.March, 27, 12, 0, 0, 0, berlin))
+	assert.Equal(next, time.Date(2021, time.March, 29, 2, 30, 0, 0, berlin))
+}
+
+// TestCronErrors tests invalid expressions.
+func TestCronErrors(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	exprs := []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"foo * * * *",
+		"CRON_TZ=Nowhere/City * * * * *",
+		"CRON_TZ=UTC",
+	}
+	for _, expr := range exprs {
+		_, err := schedule.Parse(expr, nil)
+		assert.ErrorMatch(err, ".*invalid cron expression.*", expr)
+	}
+}
+
+// EOF
@@ -0,0 +1,30 @@
+// Tideland Go Together - Schedule
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+// Package schedule runs named jobs at the times of cron expressions.
+//
+//     s, err := schedule.New(schedule.WithLocation(berlin))
+//     ...
+//     err = s.Add("cleanup", "30 2 * * *", cleanup)
+//     err = s.Add("report", "CRON_TZ=UTC 0 0 8 * * MON-FRI", report,
+//         loop.WithOverlap(loop.OverlapQueue),
+//         loop.WithRepairer(repairer))
+//
+// Expressions have five fields (minute, hour, day of month, month, and
+// day of week) or six with the seconds first. Fields contain values,
+// names of months and days, ranges, lists, steps, and *. The macros
+// @yearly, @monthly, @weekly, @daily, and @hourly are supported too. A
+// prefix CRON_TZ=<zone> sets the time zone of an expression.
+//
+// Each job runs in a loop.Loop started with loop.GoTicked(). So options
+// like repairers, finalizers, restart policies, or the handling of
+// overlapping runs can be passed. A watchdog only watches the runs of a
+// job, not the waiting between them. Jobs() lists all jobs with their
+// next run, status, and error.
+package schedule // import "tideland.dev/go/together/schedule"
+
+// EOF
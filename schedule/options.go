@@ -0,0 +1,51 @@
+// Tideland Go Together - Schedule
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package schedule // import "tideland.dev/go/together/schedule"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// OPTIONS
+//--------------------
+
+// Option defines the signature of an option setting function.
+type Option func(s *Scheduler) error
+
+// WithContext allows to pass a context for cancellation of all jobs.
+func WithContext(ctx context.Context) Option {
+	return func(s *Scheduler) error {
+		if ctx == nil {
+			return failure.New("invalid scheduler option: context is nil")
+		}
+		s.ctx = ctx
+		return nil
+	}
+}
+
+// WithLocation sets the time zone of the cron expressions not
+// containing one. Default is time.Local.
+func WithLocation(location *time.Location) Option {
+	return func(s *Scheduler) error {
+		if location == nil {
+			return failure.New("invalid scheduler option: location is nil")
+		}
+		s.location = location
+		return nil
+	}
+}
+
+// EOF
@@ -0,0 +1,161 @@
+// Tideland Go Together - Schedule
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package schedule // import "tideland.dev/go/together/schedule"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+
+	"tideland.dev/go/together/fuse"
+	"tideland.dev/go/together/loop"
+)
+
+//--------------------
+// JOB
+//--------------------
+
+// Job describes a scheduled job.
+type Job struct {
+	Name       string
+	Expression string
+	Next       time.Time
+	Status     fuse.Status
+	Err        error
+}
+
+// job is a running job.
+type job struct {
+	name string
+	cron *Cron
+	loop *loop.Loop
+}
+
+// info returns the description of the job.
+func (j *job) info() Job {
+	return Job{
+		Name:       j.name,
+		Expression: j.cron.String(),
+		Next:       j.cron.Next(time.Now()),
+		Status:     j.loop.Status(),
+		Err:        j.loop.Err(),
+	}
+}
+
+//--------------------
+// SCHEDULER
+//--------------------
+
+// Scheduler runs named jobs at the times of their cron expressions.
+// Each job runs in its own Loop.
+type Scheduler struct {
+	mu       sync.Mutex
+	ctx      context.Context
+	cancel   func()
+	location *time.Location
+	jobs     map[string]*job
+}
+
+// New creates a Scheduler with the options.
+func New(options ...Option) (*Scheduler, error) {
+	s := &Scheduler{
+		location: time.Local,
+		jobs:     make(map[string]*job),
+	}
+	for _, option := range options {
+		if err := option(s); err != nil {
+			return nil, err
+		}
+	}
+	if s.ctx == nil {
+		s.ctx = context.Background()
+	}
+	s.ctx, s.cancel = context.WithCancel(s.ctx)
+	return s, nil
+}
+
+// Add starts a job calling fn at the times of the cron expression.
+// The loop options allow to set a repairer, finalizers, or how to
+// handle overlapping runs.
+func (s *Scheduler) Add(name, expr string, fn loop.Worker, options ...loop.Option) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx.Err() != nil {
+		return failure.New("scheduler is stopped")
+	}
+	if _, ok := s.jobs[name]; ok {
+		return failure.New("job %q already scheduled", name)
+	}
+	cron, err := Parse(expr, s.location)
+	if err != nil {
+		return err
+	}
+	options = append(append([]loop.Option{}, options...), loop.WithContext(s.ctx))
+	l, err := loop.GoTicked(cron.Ticker(), fn, options...)
+	if err != nil {
+		return failure.Annotate(err, "cannot start job %q", name)
+	}
+	s.jobs[name] = &job{
+		name: name,
+		cron: cron,
+		loop: l,
+	}
+	return nil
+}
+
+// Remove stops the job and removes it.
+func (s *Scheduler) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return failure.New("job %q not found", name)
+	}
+	j.loop.Stop()
+	delete(s.jobs, name)
+	return nil
+}
+
+// Jobs returns the descriptions of all jobs sorted by name.
+func (s *Scheduler) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j.info())
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].Name < jobs[k].Name
+	})
+	return jobs
+}
+
+// Next returns the next time the job will run.
+func (s *Scheduler) Next(name string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return time.Time{}, failure.New("job %q not found", name)
+	}
+	return j.cron.Next(time.Now()), nil
+}
+
+// Stop terminates all jobs.
+func (s *Scheduler) Stop() {
+	s.cancel()
+}
+
+// EOF
@@ -0,0 +1,112 @@
+// Tideland Go Together - Schedule - Unit Tests
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package schedule_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/together/fuse"
+	"tideland.dev/go/together/loop"
+	"tideland.dev/go/together/schedule"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestScheduler tests running and listing jobs.
+func TestScheduler(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	s, err := schedule.New(schedule.WithLocation(time.UTC))
+	assert.NoError(err)
+	defer s.Stop()
+
+	runs := make(chan time.Time, 10)
+	assert.NoError(s.Add("every-second", "* * * * * *", func(ctx context.Context) error {
+		runs <- time.Now()
+		return nil
+	}))
+	assert.NoError(s.Add("yearly", "@yearly", func(ctx context.Context) error {
+		return nil
+	}, loop.WithOverlap(loop.OverlapQueue)))
+	assert.ErrorMatch(s.Add("yearly", "@daily", nil), `.*job "yearly" already scheduled.*`)
+	assert.ErrorMatch(s.Add("invalid", "* *", nil), ".*invalid cron expression.*")
+
+	first := <-runs
+	second := <-runs
+	assert.True(second.Sub(first) > 500*time.Millisecond)
+	assert.Equal(second.Truncate(time.Second).Sub(first.Truncate(time.Second)), time.Second)
+
+	jobs := s.Jobs()
+	assert.Length(jobs, 2)
+	assert.Equal(jobs[0].Name, "every-second")
+	assert.Equal(jobs[0].Status, fuse.Working)
+	assert.Equal(jobs[1].Name, "yearly")
+	assert.Equal(jobs[1].Expression, "@yearly")
+	next, err := s.Next("yearly")
+	assert.NoError(err)
+	assert.Equal(next, time.Date(time.Now().UTC().Year()+1, time.January, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(jobs[1].Next, next)
+
+	assert.NoError(s.Remove("yearly"))
+	assert.ErrorMatch(s.Remove("yearly"), `.*job "yearly" not found.*`)
+	_, err = s.Next("yearly")
+	assert.ErrorMatch(err, `.*job "yearly" not found.*`)
+	assert.Length(s.Jobs(), 1)
+}
+
+// TestSchedulerFailingJob tests a job ending with an error
+// and stopping the scheduler.
+func TestSchedulerFailingJob(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	s, err := schedule.New()
+	assert.NoError(err)
+	stopped := make(chan error, 1)
+	assert.NoError(s.Add("failing", "* * * * * *", func(ctx context.Context) error {
+		return errors.New("failed")
+	}, loop.WithFinalizer(func(err error) error {
+		stopped <- err
+		return err
+	})))
+
+	assert.ErrorMatch(<-stopped, "failed")
+	jobs := s.Jobs()
+	assert.Length(jobs, 1)
+	assert.ErrorMatch(jobs[0].Err, "failed")
+	s.Stop()
+	assert.ErrorMatch(s.Add("late", "* * * * * *", nil), ".*scheduler is stopped.*")
+}
+
+// TestSchedulerWatchdog tests a job with a watchdog interval
+// shorter than the interval of its runs.
+func TestSchedulerWatchdog(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	s, err := schedule.New()
+	assert.NoError(err)
+	defer s.Stop()
+	assert.NoError(s.Add("watched", "@hourly", func(ctx context.Context) error {
+		return nil
+	}, loop.WithWatchdog(20*time.Millisecond)))
+
+	time.Sleep(100 * time.Millisecond)
+	jobs := s.Jobs()
+	assert.Length(jobs, 1)
+	assert.Equal(jobs[0].Status, fuse.Working)
+	assert.NoError(jobs[0].Err)
+}
+
+// EOF
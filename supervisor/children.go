@@ -0,0 +1,207 @@
+// Tideland Go Together - Supervisor
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package supervisor // import "tideland.dev/go/together/supervisor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+
+	"tideland.dev/go/together/actor"
+	"tideland.dev/go/together/cells/mesh"
+	"tideland.dev/go/together/loop"
+)
+
+//--------------------
+// ACTOR
+//--------------------
+
+// Actor returns a StartFunc running an actor.Actor with the given
+// options. Each started instance is passed to assign, so that the
+// owner always works with the current one.
+func Actor(assign func(act *actor.Actor), options ...actor.Option) StartFunc {
+	return func(ctx context.Context, terminated Terminated) error {
+		act, err := actor.Go(append(
+			append([]actor.Option{}, options...),
+			actor.WithContext(ctx),
+			actor.WithFinalizer(func(err error) error {
+				terminated(err)
+				return err
+			}),
+		)...)
+		if err != nil {
+			return err
+		}
+		if assign != nil {
+			assign(act)
+		}
+		return nil
+	}
+}
+
+//--------------------
+// LOOP
+//--------------------
+
+// Loop returns a StartFunc running a loop.Loop with the given worker
+// and options. Each started instance is passed to assign, so that
+// the owner always works with the current one.
+func Loop(worker loop.Worker, assign func(l *loop.Loop), options ...loop.Option) StartFunc {
+	return func(ctx context.Context, terminated Terminated) error {
+		l, err := loop.Go(worker, append(
+			append([]loop.Option{}, options...),
+			loop.WithContext(ctx),
+			loop.WithFinalizer(func(err error) error {
+				terminated(err)
+				return err
+			}),
+		)...)
+		if err != nil {
+			return err
+		}
+		if assign != nil {
+			assign(l)
+		}
+		return nil
+	}
+}
+
+//--------------------
+// CELL
+//--------------------
+
+// Cell returns a StartFunc running the behavior as cell with the given
+// name in the mesh. The cell is deployed only once, so it keeps its
+// subscriptions. Restarts only start the behavior again. A panic of the
+// behavior is handled as termination with an error.
+func Cell(m mesh.Mesh, name string, b mesh.Behavior) StartFunc {
+	cs := &cellStarter{
+		mesh:     m,
+		name:     name,
+		behavior: b,
+		starts:   make(chan cellStart),
+	}
+	return cs.start
+}
+
+// cellStart contains the arguments of one start of a cell behavior.
+type cellStart struct {
+	ctx        context.Context
+	terminated Terminated
+}
+
+// cellStarter deploys a cell and starts its behavior on request.
+type cellStarter struct {
+	mu       sync.Mutex
+	mesh     mesh.Mesh
+	name     string
+	behavior mesh.Behavior
+	deployed bool
+	starts   chan cellStart
+}
+
+// start implements StartFunc.
+func (cs *cellStarter) start(ctx context.Context, terminated Terminated) error {
+	cs.mu.Lock()
+	if !cs.deployed {
+		if err := cs.mesh.Go(cs.name, mesh.BehaviorFunc(cs.backend)); err != nil {
+			cs.mu.Unlock()
+			return err
+		}
+		cs.deployed = true
+	}
+	cs.mu.Unlock()
+	select {
+	case cs.starts <- cellStart{ctx, terminated}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(DefaultTimeout):
+		return failure.New("cell '%s' does not accept starts", cs.name)
+	}
+}
+
+// backend is the behavior deployed in the mesh. It runs the
+// supervised behavior each time it is started.
+func (cs *cellStarter) backend(cell mesh.Cell, in mesh.Receptor, out mesh.Emitter) error {
+	for {
+		select {
+		case <-cell.Context().Done():
+			return nil
+		case start := <-cs.starts:
+			start.terminated(cs.run(start.ctx, cell, in, out))
+		}
+	}
+}
+
+// run executes the supervised behavior until it ends or one
+// of the contexts is done.
+func (cs *cellStarter) run(ctx context.Context, cell mesh.Cell, in mesh.Receptor, out mesh.Emitter) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-cell.Context().Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	defer func() {
+		if reason := recover(); reason != nil {
+			err = failure.New("cell '%s' panic: %v", cs.name, reason)
+		}
+	}()
+	return cs.behavior.Go(&supervisedCell{cell, ctx}, in, out)
+}
+
+// supervisedCell provides the context of the supervisor
+// to the behavior.
+type supervisedCell struct {
+	mesh.Cell
+	ctx context.Context
+}
+
+// Context implements mesh.Cell.
+func (sc *supervisedCell) Context() context.Context {
+	return sc.ctx
+}
+
+//--------------------
+// TREE
+//--------------------
+
+// Tree returns a StartFunc running a Supervisor with the given options
+// as child of another one. This way supervision trees can be built. Each
+// started instance is passed to assign.
+func Tree(assign func(s *Supervisor), options ...Option) StartFunc {
+	return func(ctx context.Context, terminated Terminated) error {
+		s, err := Go(append(
+			append([]Option{}, options...),
+			WithContext(ctx),
+			WithFinalizer(func(err error) error {
+				terminated(err)
+				return err
+			}),
+		)...)
+		if err != nil {
+			return err
+		}
+		if assign != nil {
+			assign(s)
+		}
+		return nil
+	}
+}
+
+// EOF
@@ -0,0 +1,32 @@
+// Tideland Go Together - Supervisor
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+// Package supervisor watches components like actors, loops, and cells
+// from the outside and restarts them when they terminate. Following the
+// Erlang/OTP ideas the strategies one-for-one, one-for-all, and
+// rest-for-one define which children are restarted together with the
+// terminated one.
+//
+//     s, err := supervisor.Go(
+//         supervisor.WithStrategy(supervisor.RestForOne),
+//         supervisor.WithRestartBudget(5, time.Minute),
+//         supervisor.WithChild("reader", supervisor.Loop(reader.worker, reader.assign)),
+//         supervisor.WithChild("cache", supervisor.Actor(cache.assign)),
+//     )
+//
+// Children are started in the order they have been added and stopped in
+// the reverse order. If more restarts than the budget allows happen during
+// the given period the supervisor stops all children and terminates with
+// an error. When running as a child of another supervisor via Tree() the
+// error is escalated to the parent this way.
+//
+// Own components can be supervised by implementing a StartFunc. It has to
+// stop the component when the passed context is cancelled and call the
+// passed Terminated function with its final error when the component ends.
+package supervisor // import "tideland.dev/go/together/supervisor"
+
+// EOF
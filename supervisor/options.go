@@ -0,0 +1,100 @@
+// Tideland Go Together - Supervisor
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package supervisor // import "tideland.dev/go/together/supervisor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+)
+
+//--------------------
+// OPTIONS
+//--------------------
+
+// Option defines the signature of an option setting function.
+type Option func(s *Supervisor) error
+
+// WithContext allows to pass a context for cancellation or timeout.
+func WithContext(ctx context.Context) Option {
+	return func(s *Supervisor) error {
+		if ctx == nil {
+			return failure.New("invalid supervisor option: context is nil")
+		}
+		s.ctx = ctx
+		return nil
+	}
+}
+
+// WithStrategy defines which children are restarted if one
+// terminates. Default is OneForOne.
+func WithStrategy(strategy Strategy) Option {
+	return func(s *Supervisor) error {
+		if strategy < OneForOne || strategy > RestForOne {
+			return failure.New("invalid supervisor option: unknown strategy %d", strategy)
+		}
+		s.strategy = strategy
+		return nil
+	}
+}
+
+// WithRestartBudget defines how many restarts are allowed during
+// the given period. If more restarts are needed the Supervisor
+// stops all children and terminates with an error.
+func WithRestartBudget(maxRestarts int, period time.Duration) Option {
+	return func(s *Supervisor) error {
+		if maxRestarts < 0 || period <= 0 {
+			return failure.New("invalid supervisor option: restart budget %d in %v", maxRestarts, period)
+		}
+		s.maxRestarts = maxRestarts
+		s.period = period
+		return nil
+	}
+}
+
+// WithTimeout defines how long the Supervisor waits for a
+// child to stop.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Supervisor) error {
+		if timeout <= 0 {
+			return failure.New("invalid supervisor option: timeout %v", timeout)
+		}
+		s.timeout = timeout
+		return nil
+	}
+}
+
+// WithChild adds a child to the Supervisor. Children are started
+// in the order they are added.
+func WithChild(name string, start StartFunc) Option {
+	return func(s *Supervisor) error {
+		_, err := s.addChild(name, start)
+		return err
+	}
+}
+
+// WithFinalizer adds a function for finalizing the work of
+// a Supervisor. Multiple finalizers are called in the order
+// they have been added, each one receiving the error returned
+// by the former one.
+func WithFinalizer(finalizer Finalizer) Option {
+	return func(s *Supervisor) error {
+		if finalizer == nil {
+			return failure.New("invalid supervisor option: finalizer is nil")
+		}
+		s.finalizers = append(s.finalizers, finalizer)
+		return nil
+	}
+}
+
+// EOF
@@ -0,0 +1,327 @@
+// Tideland Go Together - Supervisor
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package supervisor // import "tideland.dev/go/together/supervisor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"tideland.dev/go/trace/failure"
+
+	"tideland.dev/go/together/fuse"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+const (
+	// DefaultMaxRestarts is the default number of restarts allowed
+	// during the restart period.
+	DefaultMaxRestarts = 3
+
+	// DefaultPeriod is the default period of the restart budget.
+	DefaultPeriod = 5 * time.Second
+
+	// DefaultTimeout is used when waiting for children to stop.
+	DefaultTimeout = 5 * time.Second
+)
+
+// Strategy defines which children are restarted if one of
+// them terminates.
+type Strategy int
+
+// Different restart strategies.
+const (
+	// OneForOne only restarts the terminated child.
+	OneForOne Strategy = iota
+
+	// OneForAll stops all other children and restarts all.
+	OneForAll
+
+	// RestForOne stops the children started after the terminated
+	// one and restarts them together with the terminated one.
+	RestForOne
+)
+
+//--------------------
+// FUNCTION TYPES
+//--------------------
+
+// Terminated has to be called by a child with its final error
+// when it terminates.
+type Terminated func(err error)
+
+// StartFunc starts a child of a Supervisor. The child has to stop when
+// the passed context is cancelled and call terminated when it ends.
+type StartFunc func(ctx context.Context, terminated Terminated) error
+
+// Finalizer is called with the final error when the Supervisor
+// terminates.
+type Finalizer func(err error) error
+
+//--------------------
+// CHILD
+//--------------------
+
+// child contains the information about one supervised child.
+type child struct {
+	name       string
+	start      StartFunc
+	generation int
+	cancel     func()
+	done       chan struct{}
+}
+
+// termination is sent by a child when it ended.
+type termination struct {
+	child      *child
+	generation int
+	err        error
+}
+
+//--------------------
+// SUPERVISOR
+//--------------------
+
+// Supervisor starts and watches children and restarts them following
+// its strategy when they terminate.
+type Supervisor struct {
+	mu           sync.Mutex
+	ctx          context.Context
+	cancel       func()
+	strategy     Strategy
+	maxRestarts  int
+	period       time.Duration
+	timeout      time.Duration
+	children     []*child
+	reasons      fuse.Reasons
+	terminations chan termination
+	finalizers   []Finalizer
+	works        bool
+	done         chan struct{}
+	err          error
+}
+
+// Go starts a Supervisor with the passed options. Children passed
+// via options are started in their order.
+func Go(options ...Option) (*Supervisor, error) {
+	// Init with options.
+	s := &Supervisor{
+		strategy:     OneForOne,
+		maxRestarts:  DefaultMaxRestarts,
+		period:       DefaultPeriod,
+		timeout:      DefaultTimeout,
+		terminations: make(chan termination),
+		works:        true,
+		done:         make(chan struct{}),
+	}
+	for _, option := range options {
+		if err := option(s); err != nil {
+			return nil, err
+		}
+	}
+	// Ensure default settings.
+	if s.ctx == nil {
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+	} else {
+		s.ctx, s.cancel = context.WithCancel(s.ctx)
+	}
+	// Start the children.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.children {
+		if err := s.startChild(c); err != nil {
+			s.stopAll()
+			s.cancel()
+			return nil, err
+		}
+	}
+	go s.backend()
+	return s, nil
+}
+
+// Go adds a child to the running Supervisor and starts it.
+func (s *Supervisor) Go(name string, start StartFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.works {
+		return failure.New("supervisor doesn't work anymore")
+	}
+	c, err := s.addChild(name, start)
+	if err != nil {
+		return err
+	}
+	if err := s.startChild(c); err != nil {
+		s.children = s.children[:len(s.children)-1]
+		return err
+	}
+	return nil
+}
+
+// Err returns information if the Supervisor has an error.
+func (s *Supervisor) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Stop terminates the Supervisor and all its children. It waits
+// until they are stopped.
+func (s *Supervisor) Stop() {
+	s.cancel()
+	<-s.done
+}
+
+// addChild appends a new child if the name isn't used yet.
+func (s *Supervisor) addChild(name string, start StartFunc) (*child, error) {
+	if start == nil {
+		return nil, failure.New("child '%s' has no start function", name)
+	}
+	for _, c := range s.children {
+		if c.name == name {
+			return nil, failure.New("child name '%s' already used", name)
+		}
+	}
+	c := &child{
+		name:  name,
+		start: start,
+	}
+	s.children = append(s.children, c)
+	return c, nil
+}
+
+// startChild starts a new generation of the child.
+func (s *Supervisor) startChild(c *child) error {
+	ctx, cancel := context.WithCancel(s.ctx)
+	done := make(chan struct{})
+	c.generation++
+	c.cancel = cancel
+	c.done = done
+	generation := c.generation
+	var once sync.Once
+	terminated := func(err error) {
+		once.Do(func() {
+			close(done)
+			go func() {
+				select {
+				case s.terminations <- termination{c, generation, err}:
+				case <-s.ctx.Done():
+				}
+			}()
+		})
+	}
+	if err := c.start(ctx, terminated); err != nil {
+		cancel()
+		return failure.Annotate(err, "cannot start child '%s'", c.name)
+	}
+	return nil
+}
+
+// stopChild cancels the child and waits until it terminated.
+func (s *Supervisor) stopChild(c *child) {
+	c.cancel()
+	select {
+	case <-c.done:
+	case <-time.After(s.timeout):
+	}
+}
+
+// stopAll stops all children in reverse order.
+func (s *Supervisor) stopAll() {
+	for i := len(s.children) - 1; i >= 0; i-- {
+		s.stopChild(s.children[i])
+	}
+}
+
+// backend runs the goroutine of the Supervisor.
+func (s *Supervisor) backend() {
+	defer s.finalize()
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.mu.Lock()
+			s.stopAll()
+			s.mu.Unlock()
+			return
+		case t := <-s.terminations:
+			if err := s.handle(t); err != nil {
+				s.mu.Lock()
+				s.err = err
+				s.stopAll()
+				s.mu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// handle checks the restart budget and restarts the
+// children depending on the strategy.
+func (s *Supervisor) handle(t termination) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.generation != t.child.generation || s.ctx.Err() != nil {
+		// Stopped by the supervisor.
+		return nil
+	}
+	// Check the restart budget.
+	reason := failure.Annotate(t.err, "child '%s' failed", t.child.name)
+	if reason == nil {
+		reason = failure.New("child '%s' terminated", t.child.name)
+	}
+	s.reasons.Append(reason)
+	s.reasons.Trim(s.maxRestarts + 1)
+	if s.reasons.Frequency(s.maxRestarts+1, s.period) {
+		return failure.New("restart budget of %d in %v exceeded: %v", s.maxRestarts, s.period, reason)
+	}
+	// Restart following the strategy.
+	index := 0
+	for i, c := range s.children {
+		if c == t.child {
+			index = i
+		}
+	}
+	var affected []*child
+	switch s.strategy {
+	case OneForOne:
+		affected = s.children[index : index+1]
+	case OneForAll:
+		affected = s.children
+	case RestForOne:
+		affected = s.children[index:]
+	}
+	for i := len(affected) - 1; i >= 0; i-- {
+		s.stopChild(affected[i])
+	}
+	for _, c := range affected {
+		if err := s.startChild(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finalize takes care for a clean supervisor finalization.
+func (s *Supervisor) finalize() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.works = false
+	s.cancel()
+	for _, finalizer := range s.finalizers {
+		s.err = finalizer(s.err)
+	}
+	close(s.done)
+}
+
+// EOF
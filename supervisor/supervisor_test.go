@@ -0,0 +1,274 @@
+// Tideland Go Together - Supervisor - Unit Tests
+//
+// Copyright (C) 2021 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package supervisor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/together/actor"
+	"tideland.dev/go/together/cells/mesh"
+	"tideland.dev/go/together/supervisor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestOneForOne tests the restarting of only the failed child.
+func TestOneForOne(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tl := newTestLoops("a", "b")
+	s, err := supervisor.Go(
+		supervisor.WithStrategy(supervisor.OneForOne),
+		supervisor.WithChild("a", tl.child("a")),
+		supervisor.WithChild("b", tl.child("b")),
+	)
+	assert.OK(err)
+	assert.Equal(tl.started(t, 2), []string{"a", "b"})
+
+	tl.fail("a")
+	assert.Equal(tl.started(t, 1), []string{"a"})
+	tl.none(t)
+
+	s.Stop()
+	assert.NoError(s.Err())
+}
+
+// TestOneForAll tests the restarting of all children.
+func TestOneForAll(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tl := newTestLoops("a", "b", "c")
+	s, err := supervisor.Go(
+		supervisor.WithStrategy(supervisor.OneForAll),
+		supervisor.WithChild("a", tl.child("a")),
+		supervisor.WithChild("b", tl.child("b")),
+		supervisor.WithChild("c", tl.child("c")),
+	)
+	assert.OK(err)
+	assert.Equal(tl.started(t, 3), []string{"a", "b", "c"})
+
+	tl.fail("b")
+	assert.Equal(tl.started(t, 3), []string{"a", "b", "c"})
+	tl.none(t)
+
+	s.Stop()
+	assert.NoError(s.Err())
+}
+
+// TestRestForOne tests the restarting of the failed child and
+// all children started after it.
+func TestRestForOne(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tl := newTestLoops("a", "b", "c")
+	s, err := supervisor.Go(
+		supervisor.WithStrategy(supervisor.RestForOne),
+		supervisor.WithChild("a", tl.child("a")),
+		supervisor.WithChild("b", tl.child("b")),
+	)
+	assert.OK(err)
+	assert.OK(s.Go("c", tl.child("c")))
+	assert.Equal(tl.started(t, 3), []string{"a", "b", "c"})
+	assert.ErrorMatch(s.Go("c", tl.child("c")), ".*child name 'c' already used.*")
+
+	tl.fail("b")
+	assert.Equal(tl.started(t, 2), []string{"b", "c"})
+	tl.none(t)
+
+	s.Stop()
+	assert.NoError(s.Err())
+}
+
+// TestRestartBudget tests the termination of the supervisor if
+// the restart budget is exceeded.
+func TestRestartBudget(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	finalized := make(chan error, 1)
+	worker := func(ctx context.Context) error {
+		return errors.New("ouch")
+	}
+	s, err := supervisor.Go(
+		supervisor.WithRestartBudget(3, time.Second),
+		supervisor.WithChild("failing", supervisor.Loop(worker, nil)),
+		supervisor.WithFinalizer(func(err error) error {
+			finalized <- err
+			return err
+		}),
+	)
+	assert.OK(err)
+
+	select {
+	case err := <-finalized:
+		assert.ErrorMatch(err, ".*restart budget of 3 in 1s exceeded.*ouch.*")
+	case <-time.After(time.Second):
+		assert.Fail("supervisor not terminated")
+	}
+	assert.ErrorMatch(s.Err(), ".*restart budget.*")
+	assert.ErrorMatch(s.Go("other", supervisor.Loop(worker, nil)), ".*doesn't work anymore.*")
+}
+
+// TestActorChild tests the supervision of an actor.
+func TestActorChild(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	acts := make(chan *actor.Actor, 2)
+	s, err := supervisor.Go(
+		supervisor.WithChild("actor", supervisor.Actor(func(act *actor.Actor) {
+			acts <- act
+		})),
+	)
+	assert.OK(err)
+
+	act := <-acts
+	assert.OK(act.DoAsync(func() {
+		panic("bam")
+	}))
+	restarted := <-acts
+	assert.True(act != restarted)
+	assert.ErrorMatch(act.Err(), ".*actor panic: bam.*")
+	assert.OK(restarted.DoSync(func() {}))
+
+	s.Stop()
+	assert.ErrorMatch(restarted.DoSync(func() {}), "actor doesn't work anymore")
+}
+
+// TestCellChild tests the supervision of a cell behavior.
+func TestCellChild(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msh := mesh.New(ctx)
+	topics := make(chan string, 1)
+	behavior := mesh.NewRequestBehavior(func(cell mesh.Cell, evt mesh.Event, out mesh.Emitter) error {
+		if evt.Topic() == "crash" {
+			panic("crash")
+		}
+		topics <- evt.Topic()
+		return nil
+	})
+	s, err := supervisor.Go(
+		supervisor.WithChild("cell", supervisor.Cell(msh, "cell", behavior)),
+	)
+	assert.OK(err)
+
+	assert.OK(msh.Emit("cell", "one"))
+	assert.Equal(<-topics, "one")
+	assert.OK(msh.Emit("cell", "crash"))
+	assert.OK(msh.Emit("cell", "two"))
+	assert.Equal(<-topics, "two")
+
+	s.Stop()
+	assert.NoError(s.Err())
+}
+
+// TestTree tests the escalation of errors in a supervision tree.
+func TestTree(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tl := newTestLoops("a", "b")
+	subs := make(chan *supervisor.Supervisor, 10)
+	s, err := supervisor.Go(
+		supervisor.WithRestartBudget(1, time.Minute),
+		supervisor.WithChild("a", tl.child("a")),
+		supervisor.WithChild("sub", supervisor.Tree(
+			func(sub *supervisor.Supervisor) {
+				subs <- sub
+			},
+			supervisor.WithRestartBudget(0, time.Minute),
+			supervisor.WithChild("b", tl.child("b")),
+		)),
+	)
+	assert.OK(err)
+	assert.Equal(tl.started(t, 2), []string{"a", "b"})
+	sub := <-subs
+
+	// Sub-supervisor has no budget, so it escalates.
+	tl.fail("b")
+	assert.Equal(tl.started(t, 1), []string{"b"})
+	restartedSub := <-subs
+	assert.True(sub != restartedSub)
+	assert.ErrorMatch(sub.Err(), ".*restart budget of 0.*")
+	assert.NoError(s.Err())
+
+	s.Stop()
+	assert.NoError(s.Err())
+}
+
+//--------------------
+// HELPER
+//--------------------
+
+// testLoops provides supervised loops reporting their starts and
+// which can be made failing.
+type testLoops struct {
+	starts chan string
+	fails  map[string]chan struct{}
+}
+
+// newTestLoops creates the test loops for the given names.
+func newTestLoops(names ...string) *testLoops {
+	tl := &testLoops{
+		starts: make(chan string, 100),
+		fails:  make(map[string]chan struct{}),
+	}
+	for _, name := range names {
+		tl.fails[name] = make(chan struct{})
+	}
+	return tl
+}
+
+// child returns the start function of the named loop.
+func (tl *testLoops) child(name string) supervisor.StartFunc {
+	failc := tl.fails[name]
+	worker := func(ctx context.Context) error {
+		tl.starts <- name
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-failc:
+			return errors.New("failed")
+		}
+	}
+	return supervisor.Loop(worker, nil)
+}
+
+// fail lets the named loop fail.
+func (tl *testLoops) fail(name string) {
+	tl.fails[name] <- struct{}{}
+}
+
+// started returns the names of the next n started loops.
+func (tl *testLoops) started(t *testing.T, n int) []string {
+	var names []string
+	for i := 0; i < n; i++ {
+		select {
+		case name := <-tl.starts:
+			names = append(names, name)
+		case <-time.After(time.Second):
+			t.Fatalf("only %d of %d loops started", i, n)
+		}
+	}
+	return names
+}
+
+// none checks that no further loop has been started.
+func (tl *testLoops) none(t *testing.T) {
+	select {
+	case name := <-tl.starts:
+		t.Fatalf("unexpected start of loop %q", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// EOF